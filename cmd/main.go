@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"os"
 
 	"github.com/DukeRupert/rr/internal/api"
 	"github.com/DukeRupert/rr/internal/config"
@@ -9,11 +12,19 @@ import (
 	"github.com/DukeRupert/rr/internal/email"
 	"github.com/DukeRupert/rr/internal/orderspace"
 	"github.com/DukeRupert/rr/internal/services"
+	"github.com/DukeRupert/rr/internal/unsubscribe"
 
 	"github.com/labstack/echo/v4"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// Initialize Echo
 	e := echo.New()
 
@@ -44,22 +55,81 @@ func main() {
 	}
 
 	// Initialize Orderspace client
-	orderspaceClient, err := orderspace.NewClient(cfg.OrderspaceClientID, cfg.OrderspaceClientSecret, db)
+	tokenStore, err := orderspace.NewSQLiteTokenStore(db, orderspace.WithTokenEncryptionKey(cfg.TokenEncryptionKey))
+	if err != nil {
+		log.Fatalf("Failed to initialize token store: %v", err)
+	}
+
+	orderspaceClient, err := orderspace.NewClient(cfg.OrderspaceClientID, cfg.OrderspaceClientSecret, tokenStore)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	// Setup routes
-	api.SetupRoutes(e, orderspaceClient, emailClient, db)
+	signer := unsubscribe.NewSigner([]byte(cfg.UnsubscribeSecret))
+	mailer := services.NewMailer(db, orderspaceClient, emailClient, signer, cfg.PublicBaseURL)
 
 	// Initialize reminder service
-	reminderService, err := services.NewReminderScheduler(db, orderspaceClient, emailClient)
+	reminderService, err := services.NewReminderScheduler(mailer, database.NewReminderSchedules(db))
 	if err != nil {
 		log.Fatalf("Failed to create reminder service: %v", err)
 	}
+
+	// Setup routes
+	if cfg.AdminAPIKey == "" {
+		log.Println("ADMIN_API_KEY is not set; admin routes are disabled")
+	}
+	api.SetupRoutes(e, cfg, orderspaceClient, emailClient, db, mailer, signer, reminderService)
+	mirrorSync := services.NewMirrorSync(database.NewMirror(db), orderspaceClient)
+	if err := reminderService.ScheduleSync(mirrorSync, cfg.SyncInterval); err != nil {
+		log.Fatalf("Failed to schedule mirror sync: %v", err)
+	}
 	reminderService.Start()
 	defer reminderService.Shutdown()
 
 	// Start server
 	e.Logger.Fatal(e.Start(":8080"))
 }
+
+// runCommand handles CLI subcommands, which run instead of the server
+func runCommand(args []string) error {
+	if args[0] != "migrate" || len(args) != 2 {
+		return fmt.Errorf("usage: %s migrate status|up", os.Args[0])
+	}
+
+	db, err := database.Open(config.DatabaseURL())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	switch args[1] {
+	case "status":
+		statuses, err := database.Status(ctx, db)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d  %-32s %s\n", s.Version, s.Name, applied)
+		}
+		return nil
+	case "up":
+		ran, err := database.Migrate(ctx, db)
+		if err != nil {
+			return err
+		}
+		for _, m := range ran {
+			fmt.Printf("applied %04d_%s\n", m.Version, m.Name)
+		}
+		if len(ran) == 0 {
+			fmt.Println("database is up to date")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[1])
+	}
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// requireAPIKey guards admin routes with the configured API key, sent as
+// either "Authorization: Bearer <key>" or "X-API-Key: <key>". When no key
+// is configured the routes are refused outright rather than left open.
+func requireAPIKey(key string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if key == "" {
+				return echo.NewHTTPError(http.StatusServiceUnavailable, "admin API is disabled: ADMIN_API_KEY is not set")
+			}
+
+			provided := c.Request().Header.Get("X-API-Key")
+			if auth := c.Request().Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+				provided = strings.TrimPrefix(auth, "Bearer ")
+			}
+
+			if subtle.ConstantTimeCompare([]byte(provided), []byte(key)) != 1 {
+				return echo.NewHTTPError(http.StatusUnauthorized, "invalid or missing API key")
+			}
+
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/DukeRupert/rr/internal/orderspace"
+	"github.com/labstack/echo/v4"
+)
+
+// upstreamError maps an Orderspace client error onto an HTTP error for our
+// own callers. what describes the failed operation, e.g. "fetch customers".
+func upstreamError(err error, what string) error {
+	var apiErr *orderspace.APIError
+	if !errors.As(err, &apiErr) {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to "+what+": "+err.Error())
+	}
+
+	body := map[string]interface{}{
+		"error":    "Failed to " + what + ": " + apiErr.Error(),
+		"upstream": apiErr,
+	}
+
+	switch {
+	case orderspace.IsNotFound(err):
+		return echo.NewHTTPError(http.StatusNotFound, body)
+	case orderspace.IsValidation(err):
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, body)
+	case orderspace.IsRateLimited(err):
+		return echo.NewHTTPError(http.StatusServiceUnavailable, body)
+	default:
+		return echo.NewHTTPError(http.StatusBadGateway, body)
+	}
+}
@@ -2,40 +2,141 @@ package api
 
 import (
 	"database/sql"
-	"log"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/DukeRupert/rr/internal/database"
 	"github.com/DukeRupert/rr/internal/email"
+	"github.com/DukeRupert/rr/internal/models"
 	"github.com/DukeRupert/rr/internal/orderspace"
+	"github.com/DukeRupert/rr/internal/services"
+	"github.com/DukeRupert/rr/internal/unsubscribe"
 	"github.com/labstack/echo/v4"
 )
 
+// AdHocEmailRequest is a one-off email. The subject and bodies may use merge
+// fields like {{.CompanyName}} or {{.LastOrderNumber}}; see the templates
+// package for the full list. Both bodies are placed inside the shared
+// layout, which supplies the <html> document and unsubscribe footer, so
+// htmlBody should be a fragment. If a full document is sent, only what is
+// inside its <body> is used.
 type AdHocEmailRequest struct {
 	Subject  string `json:"subject"`
 	HtmlBody string `json:"htmlBody"`
 	TextBody string `json:"textBody"`
+	// Category decides which preference gates the send and which of the
+	// customer's addresses receives it; defaults to announcements
+	Category models.NotificationCategory `json:"category"`
+	// DryRun returns the rendered emails without sending them
+	DryRun bool `json:"dry_run"`
 }
 
-type AdHocEmailResponse struct {
-	Sent    int      `json:"sent"`
-	Failed  int      `json:"failed"`
-	Skipped int      `json:"skipped"`
-	Details []string `json:"details"`
+// ProductVariantRow is one product variant flattened with its parent
+// product's identifying fields
+type ProductVariantRow struct {
+	ProductID       string                  `json:"product_id"`
+	ProductCode     string                  `json:"product_code"`
+	ProductName     string                  `json:"product_name"`
+	Active          bool                    `json:"active"`
+	VariantID       string                  `json:"variant_id"`
+	SKU             string                  `json:"sku"`
+	Barcode         string                  `json:"barcode"`
+	Options         map[string]string       `json:"options"`
+	UnitPrice       float64                 `json:"unit_price"`
+	RRP             float64                 `json:"rrp"`
+	PriceListPrices []models.PriceListPrice `json:"price_list_prices"`
+	Backorder       bool                    `json:"backorder"`
+}
+
+type ProductVariantsResponse struct {
+	Variants []ProductVariantRow `json:"variants"`
+	HasMore  bool                `json:"has_more"`
 }
 
 type Handler struct {
 	client *orderspace.Client
 	email  email.Sender
 	db     *sql.DB
+	mirror *database.Mirror
+	prefs  *database.NotificationPreferences
+	mailer *services.Mailer
+	signer *unsubscribe.Signer
+
+	reminders *services.ReminderScheduler
+	schedules *database.ReminderSchedules
+	runs      *database.EmailRuns
+	templates *database.EmailTemplates
+}
+
+func NewHandler(client *orderspace.Client, emailClient email.Sender, db *sql.DB, mailer *services.Mailer, signer *unsubscribe.Signer, reminders *services.ReminderScheduler) *Handler {
+	return &Handler{
+		client: client,
+		email:  emailClient,
+		db:     db,
+		mirror: database.NewMirror(db),
+		prefs:  database.NewNotificationPreferences(db),
+		mailer: mailer,
+		signer: signer,
+
+		reminders: reminders,
+		schedules: database.NewReminderSchedules(db),
+		runs:      database.NewEmailRuns(db),
+		templates: database.NewEmailTemplates(db),
+	}
+}
+
+// wantsLive reports whether the caller asked to bypass the local mirror
+func wantsLive(c echo.Context) bool {
+	live, _ := strconv.ParseBool(c.QueryParam("live"))
+	return live
 }
 
-func NewHandler(client *orderspace.Client, emailClient email.Sender, db *sql.DB) *Handler {
-	return &Handler{client: client, email: emailClient, db: db}
+// queryList collects a multi-valued query parameter given either repeated
+// (?status=a&status=b) or comma separated (?status=a,b)
+func queryList(c echo.Context, name string) []string {
+	var values []string
+	for _, raw := range c.QueryParams()[name] {
+		for _, v := range strings.Split(raw, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+	return values
 }
 
+// GetCustomers lists customers from the local mirror, or from Orderspace
+// when ?live=true
 func (h *Handler) GetCustomers(c echo.Context) error {
+	if wantsLive(c) {
+		return h.getCustomersLive(c)
+	}
+
+	query := database.CustomerQuery{
+		Search:        c.QueryParam("q"),
+		Statuses:      queryList(c, "status"),
+		Sort:          c.QueryParam("sort"),
+		StartingAfter: c.QueryParam("starting_after"),
+	}
+	query.Limit, _ = strconv.Atoi(c.QueryParam("limit"))
+	query.Offset, _ = strconv.Atoi(c.QueryParam("offset"))
+
+	page, err := h.mirror.ListCustomers(c.Request().Context(), query)
+	if errors.Is(err, database.ErrInvalidQuery) {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to query customers: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, page)
+}
+
+func (h *Handler) getCustomersLive(c echo.Context) error {
 	limit, _ := strconv.Atoi(c.QueryParam("limit"))
 	if limit == 0 {
 		limit = 50 // default limit
@@ -46,15 +147,199 @@ func (h *Handler) GetCustomers(c echo.Context) error {
 		StartingAfter: c.QueryParam("starting_after"),
 	}
 
-	customers, err := h.client.ListCustomers(params)
+	customers, err := h.client.ListCustomersContext(c.Request().Context(), params)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch customers: "+err.Error())
+		return upstreamError(err, "fetch customers")
 	}
 
 	return c.JSON(http.StatusOK, customers)
 }
 
+func (h *Handler) GetCustomer(c echo.Context) error {
+	customer, err := h.client.GetCustomerContext(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return upstreamError(err, "fetch customer")
+	}
+
+	return c.JSON(http.StatusOK, customer)
+}
+
+func (h *Handler) CreateCustomer(c echo.Context) error {
+	var body orderspace.CustomerRequestBody
+	if err := c.Bind(&body); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
+	if err := body.ValidateCreate(); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	customer, err := h.client.CreateCustomerContext(c.Request().Context(), &orderspace.CustomerRequest{Customer: body})
+	if err != nil {
+		return upstreamError(err, "create customer")
+	}
+
+	return c.JSON(http.StatusCreated, customer)
+}
+
+func (h *Handler) UpdateCustomer(c echo.Context) error {
+	var body orderspace.CustomerRequestBody
+	if err := c.Bind(&body); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
+	if err := body.ValidateUpdate(); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	customer, err := h.client.UpdateCustomerContext(c.Request().Context(), c.Param("id"), &orderspace.CustomerRequest{Customer: body})
+	if err != nil {
+		return upstreamError(err, "update customer")
+	}
+
+	return c.JSON(http.StatusOK, customer)
+}
+
+// ensureMirrored makes sure the customer has a row in the local mirror,
+// fetching it from Orderspace if the sync hasn't picked it up yet, so rows
+// that reference customers(id) can be written
+func (h *Handler) ensureMirrored(c echo.Context, customerID string) error {
+	ctx := c.Request().Context()
+	exists, err := h.mirror.HasCustomer(ctx, customerID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to look up customer: "+err.Error())
+	}
+	if exists {
+		return nil
+	}
+
+	customer, err := h.client.GetCustomerContext(ctx, customerID)
+	if err != nil {
+		return upstreamError(err, "fetch customer")
+	}
+	if err := h.mirror.UpsertCustomer(ctx, *customer); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to store customer: "+err.Error())
+	}
+	return nil
+}
+
+func (h *Handler) GetCustomerNotifications(c echo.Context) error {
+	prefs, err := h.prefs.Get(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load notification preferences: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, prefs)
+}
+
+// UpdateCustomerNotifications sets the customer-wide email switch and/or
+// per-category preferences. Categories not mentioned are left as they are,
+// and nothing is saved unless every change is.
+func (h *Handler) UpdateCustomerNotifications(c echo.Context) error {
+	var body struct {
+		EmailNotifyDays *bool                           `json:"email_notify_days"`
+		Preferences     []models.NotificationPreference `json:"preferences"`
+	}
+	if err := c.Bind(&body); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
+	if body.EmailNotifyDays == nil && len(body.Preferences) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "email_notify_days or preferences is required")
+	}
+	for _, pref := range body.Preferences {
+		if !pref.Category.Validate() {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid category: "+string(pref.Category))
+		}
+	}
+
+	customerID := c.Param("id")
+	if err := h.ensureMirrored(c, customerID); err != nil {
+		return err
+	}
+
+	prefs, err := h.prefs.Update(c.Request().Context(), customerID, body.EmailNotifyDays, body.Preferences)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save notification preferences: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, prefs)
+}
+
+// GetCustomerOrderInterval returns how many weeks apart the customer orders,
+// which decides how often they get order reminders
+func (h *Handler) GetCustomerOrderInterval(c echo.Context) error {
+	customerID := c.Param("id")
+	weeks, err := h.mirror.OrderInterval(c.Request().Context(), customerID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load order interval: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"customer_id":    customerID,
+		"order_interval": weeks,
+	})
+}
+
+// UpdateCustomerOrderInterval sets the customer's order interval in weeks.
+// A null order_interval resets it to weekly.
+func (h *Handler) UpdateCustomerOrderInterval(c echo.Context) error {
+	var body struct {
+		OrderInterval *int `json:"order_interval"`
+	}
+	if err := c.Bind(&body); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
+	if body.OrderInterval != nil && !models.ValidOrderInterval(*body.OrderInterval) {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Sprintf("order_interval must be between %d and %d weeks", models.MinOrderInterval, models.MaxOrderInterval))
+	}
+
+	customerID := c.Param("id")
+	if err := h.ensureMirrored(c, customerID); err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	if err := h.mirror.SetOrderInterval(ctx, customerID, body.OrderInterval); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save order interval: "+err.Error())
+	}
+
+	return h.GetCustomerOrderInterval(c)
+}
+
+// GetOrders lists orders from the local mirror, or from Orderspace when
+// ?live=true
 func (h *Handler) GetOrders(c echo.Context) error {
+	if wantsLive(c) {
+		return h.getOrdersLive(c)
+	}
+
+	query := database.OrderQuery{
+		Search:             c.QueryParam("q"),
+		Statuses:           queryList(c, "status"),
+		CustomerID:         c.QueryParam("customer_id"),
+		StandingOrderID:    c.QueryParam("standing_order_id"),
+		CreatedSince:       c.QueryParam("created_since"),
+		CreatedBefore:      c.QueryParam("created_before"),
+		DeliveryDateSince:  c.QueryParam("delivery_date_since"),
+		DeliveryDateBefore: c.QueryParam("delivery_date_before"),
+		Sort:               c.QueryParam("sort"),
+		StartingAfter:      c.QueryParam("starting_after"),
+	}
+	query.Number, _ = strconv.Atoi(c.QueryParam("number"))
+	query.Limit, _ = strconv.Atoi(c.QueryParam("limit"))
+	query.Offset, _ = strconv.Atoi(c.QueryParam("offset"))
+
+	page, err := h.mirror.ListOrders(c.Request().Context(), query)
+	if errors.Is(err, database.ErrInvalidQuery) {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to query orders: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, page)
+}
+
+func (h *Handler) getOrdersLive(c echo.Context) error {
 	params := &orderspace.OrderListParams{}
 
 	// Parse query parameters
@@ -96,81 +381,206 @@ func (h *Handler) GetOrders(c echo.Context) error {
 	}
 
 	// Call the OrderSpace API
-	response, err := h.client.ListOrders(params)
+	response, err := h.client.ListOrdersContext(c.Request().Context(), params)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return upstreamError(err, "fetch orders")
 	}
 
 	return c.JSON(http.StatusOK, response)
 }
 
-func (h *Handler) SendAdHocEmail(c echo.Context) error {
-	var req AdHocEmailRequest
-	if err := c.Bind(&req); err != nil {
+func (h *Handler) GetOrder(c echo.Context) error {
+	order, err := h.client.GetOrderContext(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return upstreamError(err, "fetch order")
+	}
+
+	return c.JSON(http.StatusOK, order)
+}
+
+func (h *Handler) UpdateOrder(c echo.Context) error {
+	var body orderspace.OrderUpdateBody
+	if err := c.Bind(&body); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body: "+err.Error())
 	}
+	if err := body.Validate(); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
 
-	if req.Subject == "" {
-		return echo.NewHTTPError(http.StatusBadRequest, "subject is required")
+	order, err := h.client.UpdateOrderContext(c.Request().Context(), c.Param("id"), &orderspace.OrderUpdateRequest{Order: body})
+	if err != nil {
+		return upstreamError(err, "update order")
 	}
-	if req.HtmlBody == "" && req.TextBody == "" {
-		return echo.NewHTTPError(http.StatusBadRequest, "htmlBody or textBody is required")
+
+	return c.JSON(http.StatusOK, order)
+}
+
+func (h *Handler) CancelOrder(c echo.Context) error {
+	order, err := h.client.CancelOrderContext(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return upstreamError(err, "cancel order")
 	}
 
-	sixWeeksAgo := time.Now().AddDate(0, 0, -42)
-	params := &orderspace.CustomerListParams{
-		UpdatedSince: &sixWeeksAgo,
+	return c.JSON(http.StatusOK, order)
+}
+
+func (h *Handler) AddOrderLines(c echo.Context) error {
+	var body struct {
+		OrderLines []orderspace.OrderLineRequest `json:"order_lines"`
+	}
+	if err := c.Bind(&body); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
+	if len(body.OrderLines) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "order_lines is required")
+	}
+	update := orderspace.OrderUpdateBody{OrderLines: body.OrderLines}
+	if err := update.Validate(); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	resp, err := h.client.ListCustomers(params)
+	order, err := h.client.AddOrderLinesContext(c.Request().Context(), c.Param("id"), body.OrderLines...)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to fetch customers: "+err.Error())
+		return upstreamError(err, "add order lines")
 	}
 
-	result := AdHocEmailResponse{
-		Details: []string{},
+	return c.JSON(http.StatusOK, order)
+}
+
+func (h *Handler) UpdateOrderLine(c echo.Context) error {
+	var change orderspace.OrderLineChange
+	if err := c.Bind(&change); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
+	if change.Quantity == nil && change.OnHold == nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "quantity or on_hold is required")
+	}
+	if change.Quantity != nil && *change.Quantity < 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "quantity cannot be negative")
+	}
+
+	order, err := h.client.UpdateOrderLineContext(c.Request().Context(), c.Param("id"), c.Param("line_id"), change)
+	if errors.Is(err, orderspace.ErrOrderLineNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return upstreamError(err, "update order line")
+	}
+
+	return c.JSON(http.StatusOK, order)
+}
+
+func (h *Handler) GetProducts(c echo.Context) error {
+	params := &orderspace.ProductListParams{}
+
+	// Parse query parameters
+	if limit := c.QueryParam("limit"); limit != "" {
+		if n, err := strconv.Atoi(limit); err == nil {
+			params.Limit = n
+		}
 	}
 
-	for _, customer := range resp.Customers {
-		var notifyDays bool
-		err := h.db.QueryRow(`
-			SELECT COALESCE(
-				(SELECT email_notify_days FROM customer_notifications WHERE customer_id = ?),
-				true
-			)
-		`, customer.ID).Scan(&notifyDays)
+	params.StartingAfter = c.QueryParam("starting_after")
+	params.Code = c.QueryParam("code")
+	params.Name = c.QueryParam("name")
+	params.CategoryID = c.QueryParam("category_id")
+
+	if active := c.QueryParam("active"); active != "" {
+		b, err := strconv.ParseBool(active)
 		if err != nil {
-			log.Printf("ERROR checking notification preference for %s: %v", customer.CompanyName, err)
-			result.Failed++
-			result.Details = append(result.Details, "ERROR: "+customer.CompanyName+" (failed to check preferences)")
-			continue
+			return echo.NewHTTPError(http.StatusBadRequest, "active must be true or false")
 		}
+		params.Active = orderspace.BoolPtr(b)
+	}
 
-		if !notifyDays {
-			result.Skipped++
-			result.Details = append(result.Details, "SKIPPED: "+customer.CompanyName+" (notifications disabled)")
-			continue
+	// Parse date parameters
+	if created_since := c.QueryParam("created_since"); created_since != "" {
+		if t, err := time.Parse(time.RFC3339, created_since); err == nil {
+			params.CreatedSince = &t
 		}
+	}
 
-		adHocEmail := email.Email{
-			From:     "info@rockabillyroasting.com",
-			To:       customer.EmailAddresses.Orders,
-			Subject:  req.Subject,
-			HtmlBody: req.HtmlBody,
-			TextBody: req.TextBody,
+	if updated_since := c.QueryParam("updated_since"); updated_since != "" {
+		if t, err := time.Parse(time.RFC3339, updated_since); err == nil {
+			params.UpdatedSince = &t
 		}
+	}
 
-		_, err = h.email.SendEmail(adHocEmail)
-		if err != nil {
-			log.Printf("ERROR sending ad-hoc email to %s: %v", customer.CompanyName, err)
-			result.Failed++
-			result.Details = append(result.Details, "ERROR: "+customer.CompanyName+" ("+err.Error()+")")
-		} else {
-			log.Printf("SUCCESS sent ad-hoc email to %s (%s)", customer.CompanyName, customer.EmailAddresses.Orders)
-			result.Sent++
-			result.Details = append(result.Details, "SUCCESS: "+customer.CompanyName+" ("+customer.EmailAddresses.Orders+")")
+	response, err := h.client.ListProductsContext(c.Request().Context(), params)
+	if err != nil {
+		return upstreamError(err, "fetch products")
+	}
+
+	if c.QueryParam("flatten") != "variants" {
+		return c.JSON(http.StatusOK, response)
+	}
+
+	rows := ProductVariantsResponse{
+		Variants: []ProductVariantRow{},
+		HasMore:  response.HasMore,
+	}
+	for _, product := range response.Products {
+		for _, variant := range product.ProductVariants {
+			rows.Variants = append(rows.Variants, ProductVariantRow{
+				ProductID:       product.ID,
+				ProductCode:     product.Code,
+				ProductName:     product.Name,
+				Active:          product.Active,
+				VariantID:       variant.ID,
+				SKU:             variant.SKU,
+				Barcode:         variant.Barcode,
+				Options:         variant.Options,
+				UnitPrice:       variant.UnitPrice,
+				RRP:             variant.RRP,
+				PriceListPrices: variant.PriceListPrices,
+				Backorder:       variant.Backorder,
+			})
 		}
 	}
 
-	return c.JSON(http.StatusOK, result)
+	return c.JSON(http.StatusOK, rows)
+}
+
+func (h *Handler) GetProduct(c echo.Context) error {
+	product, err := h.client.GetProductContext(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return upstreamError(err, "fetch product")
+	}
+
+	return c.JSON(http.StatusOK, product)
+}
+
+func (h *Handler) SendAdHocEmail(c echo.Context) error {
+	var req AdHocEmailRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
+
+	if req.Subject == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "subject is required")
+	}
+	if req.HtmlBody == "" && req.TextBody == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "htmlBody or textBody is required")
+	}
+	if req.Category == "" {
+		req.Category = models.NotificationAnnouncements
+	}
+	if !req.Category.Validate() {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid category: "+string(req.Category))
+	}
+
+	summary, err := h.mailer.SendAdHocEmail(c.Request().Context(), services.AdHocEmail{
+		Subject:  req.Subject,
+		HtmlBody: req.HtmlBody,
+		TextBody: req.TextBody,
+		Category: req.Category,
+	}, services.RunOptions{Trigger: models.RunTriggerManual, DryRun: req.DryRun})
+	if errors.Is(err, services.ErrInvalidTemplate) {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		return upstreamError(err, "send ad-hoc email")
+	}
+
+	return c.JSON(http.StatusOK, summary)
 }
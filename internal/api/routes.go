@@ -4,26 +4,57 @@ import (
 	"database/sql"
 	"net/http"
 
+	"github.com/DukeRupert/rr/internal/config"
 	"github.com/DukeRupert/rr/internal/email"
 	"github.com/DukeRupert/rr/internal/orderspace"
 	"github.com/DukeRupert/rr/internal/services"
+	"github.com/DukeRupert/rr/internal/unsubscribe"
 
 	"github.com/labstack/echo/v4"
 )
 
 // routes.go
-func SetupRoutes(e *echo.Echo, client *orderspace.Client, emailClient email.Sender, db *sql.DB) {
-	h := NewHandler(client, emailClient, db)
+func SetupRoutes(e *echo.Echo, cfg *config.Config, client *orderspace.Client, emailClient email.Sender, db *sql.DB, mailer *services.Mailer, signer *unsubscribe.Signer, reminders *services.ReminderScheduler) {
+	h := NewHandler(client, emailClient, db, mailer, signer, reminders)
+	admin := requireAPIKey(cfg.AdminAPIKey)
 	e.GET("/health", func(c echo.Context) error {
 		return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
 	})
 	e.GET("/api/customers", h.GetCustomers)
+	e.POST("/api/customers", h.CreateCustomer, admin)
+	e.GET("/api/customers/:id", h.GetCustomer)
+	e.PUT("/api/customers/:id", h.UpdateCustomer, admin)
+	e.GET("/api/customers/:id/notifications", h.GetCustomerNotifications)
+	e.PUT("/api/customers/:id/notifications", h.UpdateCustomerNotifications, admin)
+	e.GET("/api/customers/:id/order-interval", h.GetCustomerOrderInterval)
+	e.PUT("/api/customers/:id/order-interval", h.UpdateCustomerOrderInterval, admin)
 	e.GET("/api/orders", h.GetOrders)
+	e.GET("/api/orders/:id", h.GetOrder)
+	e.PUT("/api/orders/:id", h.UpdateOrder, admin)
+	e.POST("/api/orders/:id/cancel", h.CancelOrder, admin)
+	e.POST("/api/orders/:id/lines", h.AddOrderLines, admin)
+	e.PATCH("/api/orders/:id/lines/:line_id", h.UpdateOrderLine, admin)
+	e.GET("/api/products", h.GetProducts)
+	e.GET("/api/products/:id", h.GetProduct)
 	e.GET("/api/email/preview-reminders", func(c echo.Context) error {
-		if err := services.PreviewOrderReminders(db, client, emailClient); err != nil {
+		if err := mailer.PreviewOrderReminders(c.Request().Context()); err != nil {
 			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		}
 		return c.JSON(http.StatusOK, map[string]string{"status": "preview sent"})
 	})
-	e.POST("/api/email/send-adhoc", h.SendAdHocEmail)
+	e.POST("/api/email/send-adhoc", h.SendAdHocEmail, admin)
+	e.POST("/api/email/reminders/run", h.RunReminders, admin)
+	e.GET("/api/email/runs", h.GetEmailRuns, admin)
+	e.GET("/api/email/runs/:id", h.GetEmailRun, admin)
+	e.POST("/api/email/runs/:id/retry-failed", h.RetryFailedEmails, admin)
+	e.GET("/api/email/templates", h.GetEmailTemplates)
+	e.GET("/api/email/templates/:name", h.GetEmailTemplate)
+	e.PUT("/api/email/templates/:name", h.UpdateEmailTemplate, admin)
+	e.GET("/api/email/schedules", h.GetReminderSchedules)
+	e.POST("/api/email/schedules", h.CreateReminderSchedule, admin)
+	e.GET("/api/email/schedules/:id", h.GetReminderSchedule)
+	e.PUT("/api/email/schedules/:id", h.UpdateReminderSchedule, admin)
+	e.DELETE("/api/email/schedules/:id", h.DeleteReminderSchedule, admin)
+	e.GET("/unsubscribe/:token", h.ShowUnsubscribe)
+	e.POST("/unsubscribe/:token", h.Unsubscribe)
 }
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/DukeRupert/rr/internal/models"
+	"github.com/DukeRupert/rr/internal/orderspace"
+	"github.com/DukeRupert/rr/internal/services"
+	"github.com/labstack/echo/v4"
+)
+
+// runID parses the :id path parameter
+func runID(c echo.Context) (int64, error) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, "invalid run id")
+	}
+	return id, nil
+}
+
+// GetEmailRuns lists reminder and ad-hoc runs, newest first
+func (h *Handler) GetEmailRuns(c echo.Context) error {
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	offset, _ := strconv.Atoi(c.QueryParam("offset"))
+
+	page, err := h.runs.List(c.Request().Context(), limit, offset)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load runs: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, page)
+}
+
+// GetEmailRun returns a run with the outcome for every recipient
+func (h *Handler) GetEmailRun(c echo.Context) error {
+	id, err := runID(c)
+	if err != nil {
+		return err
+	}
+
+	run, err := h.runs.Get(c.Request().Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return echo.NewHTTPError(http.StatusNotFound, "run not found")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load run: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, run)
+}
+
+// RunReminders sends order reminders now, to everyone the scheduled job
+// would email or only to the given customer_ids, and responds with the
+// outcome for every recipient. With dry_run it responds with the rendered
+// emails instead of sending them.
+func (h *Handler) RunReminders(c echo.Context) error {
+	var body struct {
+		CustomerIDs []string `json:"customer_ids"`
+		DryRun      bool     `json:"dry_run"`
+	}
+	if err := c.Bind(&body); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
+
+	summary, err := h.reminders.Run(c.Request().Context(), services.RunOptions{
+		Trigger:     models.RunTriggerManual,
+		CustomerIDs: body.CustomerIDs,
+		DryRun:      body.DryRun,
+	})
+	return h.runResult(c, summary, err)
+}
+
+// RetryFailedEmails re-sends the reminders that failed in a run, skipping
+// anyone who has been reminded since
+func (h *Handler) RetryFailedEmails(c echo.Context) error {
+	id, err := runID(c)
+	if err != nil {
+		return err
+	}
+
+	summary, err := h.reminders.RetryFailed(c.Request().Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return echo.NewHTTPError(http.StatusNotFound, "run not found")
+	}
+	if errors.Is(err, services.ErrCannotRetry) {
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	}
+	return h.runResult(c, summary, err)
+}
+
+// runResult responds with the recorded run, including per-recipient
+// outcomes. A run that started but failed part way is still returned, with
+// its error, so callers can see who was emailed before it stopped.
+func (h *Handler) runResult(c echo.Context, summary *services.SendSummary, runErr error) error {
+	if summary == nil || (summary.DryRun && runErr != nil) {
+		return upstreamError(runErr, "run reminders")
+	}
+	if summary.DryRun {
+		return c.JSON(http.StatusOK, summary)
+	}
+
+	run, err := h.runs.Get(c.Request().Context(), summary.RunID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load run: "+err.Error())
+	}
+
+	status := http.StatusOK
+	switch {
+	case runErr == nil:
+	case orderspace.IsNotFound(runErr):
+		status = http.StatusNotFound
+	default:
+		status = http.StatusBadGateway
+	}
+	return c.JSON(status, run)
+}
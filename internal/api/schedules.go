@@ -0,0 +1,158 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/DukeRupert/rr/internal/models"
+	"github.com/DukeRupert/rr/internal/services"
+	"github.com/labstack/echo/v4"
+)
+
+// ReminderScheduleRequest is the body for creating or replacing a reminder
+// schedule. Timezone defaults to America/Denver and Enabled to true.
+type ReminderScheduleRequest struct {
+	Name           string        `json:"name"`
+	CronExpression string        `json:"cron_expression"`
+	Weekday        *time.Weekday `json:"weekday"`
+	TimeOfDay      string        `json:"time_of_day"`
+	Timezone       string        `json:"timezone"`
+	Enabled        *bool         `json:"enabled"`
+}
+
+func (r ReminderScheduleRequest) schedule() models.ReminderSchedule {
+	s := models.ReminderSchedule{
+		Name:           r.Name,
+		CronExpression: r.CronExpression,
+		Weekday:        r.Weekday,
+		TimeOfDay:      r.TimeOfDay,
+		Timezone:       r.Timezone,
+		Enabled:        true,
+	}
+	if s.Timezone == "" {
+		s.Timezone = "America/Denver"
+	}
+	if r.Enabled != nil {
+		s.Enabled = *r.Enabled
+	}
+	return s
+}
+
+func scheduleID(c echo.Context) (int64, error) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, "invalid schedule id")
+	}
+	return id, nil
+}
+
+func (h *Handler) bindSchedule(c echo.Context) (models.ReminderSchedule, error) {
+	var req ReminderScheduleRequest
+	if err := c.Bind(&req); err != nil {
+		return models.ReminderSchedule{}, echo.NewHTTPError(http.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
+	schedule := req.schedule()
+	if err := services.ValidateSchedule(schedule); err != nil {
+		return models.ReminderSchedule{}, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return schedule, nil
+}
+
+// reloadSchedules applies a schedule change to the running scheduler and
+// responds with the saved schedule
+func (h *Handler) reloadSchedules(c echo.Context, status int, schedule *models.ReminderSchedule) error {
+	if err := h.reminders.Reload(c.Request().Context()); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Schedule saved but failed to reload scheduler: "+err.Error())
+	}
+	if schedule == nil {
+		return c.NoContent(status)
+	}
+	schedule.NextRun = h.reminders.NextRun(schedule.ID)
+	return c.JSON(status, schedule)
+}
+
+func (h *Handler) GetReminderSchedules(c echo.Context) error {
+	schedules, err := h.schedules.List(c.Request().Context())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load reminder schedules: "+err.Error())
+	}
+	for i := range schedules {
+		schedules[i].NextRun = h.reminders.NextRun(schedules[i].ID)
+	}
+
+	return c.JSON(http.StatusOK, schedules)
+}
+
+func (h *Handler) GetReminderSchedule(c echo.Context) error {
+	id, err := scheduleID(c)
+	if err != nil {
+		return err
+	}
+
+	schedule, err := h.schedules.Get(c.Request().Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return echo.NewHTTPError(http.StatusNotFound, "reminder schedule not found")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load reminder schedule: "+err.Error())
+	}
+	schedule.NextRun = h.reminders.NextRun(schedule.ID)
+
+	return c.JSON(http.StatusOK, schedule)
+}
+
+func (h *Handler) CreateReminderSchedule(c echo.Context) error {
+	schedule, err := h.bindSchedule(c)
+	if err != nil {
+		return err
+	}
+
+	saved, err := h.schedules.Create(c.Request().Context(), schedule)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save reminder schedule: "+err.Error())
+	}
+
+	return h.reloadSchedules(c, http.StatusCreated, saved)
+}
+
+func (h *Handler) UpdateReminderSchedule(c echo.Context) error {
+	id, err := scheduleID(c)
+	if err != nil {
+		return err
+	}
+	schedule, err := h.bindSchedule(c)
+	if err != nil {
+		return err
+	}
+	schedule.ID = id
+
+	saved, err := h.schedules.Update(c.Request().Context(), schedule)
+	if errors.Is(err, sql.ErrNoRows) {
+		return echo.NewHTTPError(http.StatusNotFound, "reminder schedule not found")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save reminder schedule: "+err.Error())
+	}
+
+	return h.reloadSchedules(c, http.StatusOK, saved)
+}
+
+func (h *Handler) DeleteReminderSchedule(c echo.Context) error {
+	id, err := scheduleID(c)
+	if err != nil {
+		return err
+	}
+
+	err = h.schedules.Delete(c.Request().Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return echo.NewHTTPError(http.StatusNotFound, "reminder schedule not found")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete reminder schedule: "+err.Error())
+	}
+
+	return h.reloadSchedules(c, http.StatusNoContent, nil)
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/DukeRupert/rr/internal/models"
+	"github.com/DukeRupert/rr/internal/templates"
+	"github.com/labstack/echo/v4"
+)
+
+// EmailTemplateRequest is the body for replacing a template
+type EmailTemplateRequest struct {
+	Subject  string `json:"subject"`
+	HtmlBody string `json:"html_body"`
+	TextBody string `json:"text_body"`
+}
+
+// GetEmailTemplates lists every editable email template
+func (h *Handler) GetEmailTemplates(c echo.Context) error {
+	list, err := h.templates.List(c.Request().Context())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load email templates: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, list)
+}
+
+// GetEmailTemplate returns one template by name
+func (h *Handler) GetEmailTemplate(c echo.Context) error {
+	tmpl, err := h.templates.Get(c.Request().Context(), c.Param("name"))
+	if errors.Is(err, sql.ErrNoRows) {
+		return echo.NewHTTPError(http.StatusNotFound, "email template not found")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load email template: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, tmpl)
+}
+
+// UpdateEmailTemplate replaces a template after checking it renders with
+// sample data, so a typo is rejected here rather than at send time
+func (h *Handler) UpdateEmailTemplate(c echo.Context) error {
+	var req EmailTemplateRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body: "+err.Error())
+	}
+
+	ctx := c.Request().Context()
+	tmpl := models.EmailTemplate{
+		Name:     c.Param("name"),
+		Subject:  req.Subject,
+		HtmlBody: req.HtmlBody,
+		TextBody: req.TextBody,
+	}
+
+	if tmpl.Name == templates.LayoutName {
+		if err := templates.ValidateLayout(tmpl); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid template: "+err.Error())
+		}
+	} else {
+		if tmpl.Subject == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "subject is required")
+		}
+		if tmpl.HtmlBody == "" && tmpl.TextBody == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "html_body or text_body is required")
+		}
+		layout, err := h.templates.Get(ctx, templates.LayoutName)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load layout template: "+err.Error())
+		}
+		if err := templates.Validate(*layout, tmpl); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid template: "+err.Error())
+		}
+	}
+
+	saved, err := h.templates.Update(ctx, tmpl)
+	if errors.Is(err, sql.ErrNoRows) {
+		return echo.NewHTTPError(http.StatusNotFound, "email template not found")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save email template: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, saved)
+}
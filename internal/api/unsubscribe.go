@@ -0,0 +1,104 @@
+package api
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+
+	"github.com/DukeRupert/rr/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html>
+    <head>
+        <meta charset="utf-8">
+        <meta name="viewport" content="width=device-width, initial-scale=1">
+        <title>Rockabilly Roasting - Unsubscribe</title>
+    </head>
+    <body style="font-family:sans-serif;max-width:32rem;margin:3rem auto;padding:0 1rem;">
+        <h2>{{.Heading}}</h2>
+        <p>{{.Message}}</p>
+        {{if .Confirm}}
+        <form method="POST">
+            <button type="submit">Unsubscribe</button>
+        </form>
+        {{end}}
+    </body>
+</html>
+`))
+
+type unsubscribeView struct {
+	Heading string
+	Message string
+	Confirm bool
+}
+
+var categoryLabels = map[models.NotificationCategory]string{
+	models.NotificationOrderReminders:  "order reminders",
+	models.NotificationAnnouncements:   "announcements",
+	models.NotificationDispatchNotices: "dispatch notices",
+	models.NotificationInvoices:        "invoices",
+}
+
+func renderUnsubscribe(c echo.Context, status int, view unsubscribeView) error {
+	c.Response().Header().Set(echo.HeaderContentType, echo.MIMETextHTMLCharsetUTF8)
+	c.Response().WriteHeader(status)
+	return unsubscribePage.Execute(c.Response(), view)
+}
+
+var invalidUnsubscribeLink = unsubscribeView{
+	Heading: "Link not recognised",
+	Message: "This unsubscribe link is invalid. Reply to any of our emails and we'll sort it out for you.",
+}
+
+// ShowUnsubscribe asks the recipient to confirm. Mail scanners follow GET
+// links, so only the POST actually changes anything.
+func (h *Handler) ShowUnsubscribe(c echo.Context) error {
+	claims, err := h.signer.Parse(c.Param("token"))
+	if err != nil {
+		return renderUnsubscribe(c, http.StatusBadRequest, invalidUnsubscribeLink)
+	}
+
+	return renderUnsubscribe(c, http.StatusOK, unsubscribeView{
+		Heading: "Unsubscribe",
+		Message: "Stop sending " + categoryLabels[claims.Category] + " to " + claims.EmailAddress + "?",
+		Confirm: true,
+	})
+}
+
+// Unsubscribe turns off the category for the address in the token. It
+// serves both the confirmation form and RFC 8058 one-click requests.
+func (h *Handler) Unsubscribe(c echo.Context) error {
+	claims, err := h.signer.Parse(c.Param("token"))
+	if err != nil {
+		return renderUnsubscribe(c, http.StatusBadRequest, invalidUnsubscribeLink)
+	}
+
+	if err := h.ensureMirrored(c, claims.CustomerID); err != nil {
+		log.Printf("ERROR unsubscribing %s: %v", claims.CustomerID, err)
+		return renderUnsubscribe(c, http.StatusInternalServerError, unsubscribeView{
+			Heading: "Something went wrong",
+			Message: "We couldn't update your preferences. Please try again later.",
+		})
+	}
+
+	err = h.prefs.SetCategory(c.Request().Context(), claims.CustomerID, models.NotificationPreference{
+		Category:     claims.Category,
+		EmailAddress: claims.EmailAddress,
+		Enabled:      false,
+	})
+	if err != nil {
+		log.Printf("ERROR unsubscribing %s: %v", claims.CustomerID, err)
+		return renderUnsubscribe(c, http.StatusInternalServerError, unsubscribeView{
+			Heading: "Something went wrong",
+			Message: "We couldn't update your preferences. Please try again later.",
+		})
+	}
+
+	log.Printf("UNSUBSCRIBED %s (%s) from %s", claims.CustomerID, claims.EmailAddress, claims.Category)
+	return renderUnsubscribe(c, http.StatusOK, unsubscribeView{
+		Heading: "You're unsubscribed",
+		Message: claims.EmailAddress + " will no longer receive " + categoryLabels[claims.Category] + " from Rockabilly Roasting.",
+	})
+}
@@ -2,8 +2,10 @@
 package config
 
 import (
+	"encoding/base64"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -15,6 +17,18 @@ type Config struct {
 	PostmarkServerToken    string
 	SMTPHost               string
 	SMTPPort               string
+	TokenEncryptionKey     []byte
+	AdminAPIKey            string
+	SyncInterval           time.Duration
+	UnsubscribeSecret      string
+	PublicBaseURL          string
+}
+
+// DatabaseURL returns the configured database path without validating the
+// rest of the configuration, for CLI commands that only touch the database
+func DatabaseURL() string {
+	_ = godotenv.Load()
+	return os.Getenv("DATABASE_URL")
 }
 
 func Load() (*Config, error) {
@@ -36,11 +50,42 @@ func Load() (*Config, error) {
 		}
 	}
 
+	// Every customer email carries a signed unsubscribe link that points
+	// back at this service, so neither can be left unset
+	unsubscribeSecret := os.Getenv("UNSUBSCRIBE_SECRET")
+	publicBaseURL := os.Getenv("PUBLIC_BASE_URL")
+	if unsubscribeSecret == "" || publicBaseURL == "" {
+		return nil, fmt.Errorf("UNSUBSCRIBE_SECRET and PUBLIC_BASE_URL are required to sign the unsubscribe links in customer emails")
+	}
+
 	postmarkToken := os.Getenv("POSTMARK_SERVER_TOKEN")
 	if smtpHost == "" && postmarkToken == "" {
 		return nil, fmt.Errorf("either SMTP_HOST or POSTMARK_SERVER_TOKEN is required")
 	}
 
+	// Optional key for encrypting stored Orderspace tokens at rest
+	var tokenKey []byte
+	if encoded := os.Getenv("TOKEN_ENCRYPTION_KEY"); encoded != "" {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("TOKEN_ENCRYPTION_KEY must be base64 encoded: %w", err)
+		}
+		if len(key) != 16 && len(key) != 24 && len(key) != 32 {
+			return nil, fmt.Errorf("TOKEN_ENCRYPTION_KEY must decode to 16, 24 or 32 bytes")
+		}
+		tokenKey = key
+	}
+
+	// How often the local mirror pulls changes from Orderspace
+	syncInterval := 15 * time.Minute
+	if raw := os.Getenv("SYNC_INTERVAL"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("SYNC_INTERVAL must be a positive duration such as 15m")
+		}
+		syncInterval = d
+	}
+
 	return &Config{
 		OrderspaceClientID:     requiredEnvVars["ORDERSPACE_CLIENT_ID"],
 		OrderspaceClientSecret: requiredEnvVars["ORDERSPACE_CLIENT_SECRET"],
@@ -48,5 +93,10 @@ func Load() (*Config, error) {
 		DatabaseURL:            os.Getenv("DATABASE_URL"),
 		SMTPHost:               smtpHost,
 		SMTPPort:               smtpPort,
+		TokenEncryptionKey:     tokenKey,
+		AdminAPIKey:            os.Getenv("ADMIN_API_KEY"),
+		SyncInterval:           syncInterval,
+		UnsubscribeSecret:      unsubscribeSecret,
+		PublicBaseURL:          publicBaseURL,
 	}, nil
 }
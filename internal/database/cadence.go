@@ -0,0 +1,90 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DukeRupert/rr/internal/models"
+)
+
+// OrderInterval returns how many weeks apart the customer orders, falling
+// back to weekly when it hasn't been set or the customer isn't mirrored yet
+func (m *Mirror) OrderInterval(ctx context.Context, customerID string) (int, error) {
+	var weeks sql.NullInt64
+	err := m.db.QueryRowContext(ctx, `
+        SELECT order_interval FROM customers WHERE id = ?
+    `, customerID).Scan(&weeks)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !weeks.Valid) {
+		return models.DefaultOrderInterval, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return int(weeks.Int64), nil
+}
+
+// SetOrderInterval sets the customer's ordering cadence in weeks. A nil
+// interval clears it back to the weekly default. It returns sql.ErrNoRows
+// if the customer isn't mirrored.
+func (m *Mirror) SetOrderInterval(ctx context.Context, customerID string, weeks *int) error {
+	res, err := m.db.ExecContext(ctx, `
+        UPDATE customers SET order_interval = ? WHERE id = ?
+    `, weeks, customerID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// LastOrderDate returns when the customer's most recent non-cancelled order
+// was placed. ok is false if the mirror has no orders for them.
+func (m *Mirror) LastOrderDate(ctx context.Context, customerID string) (last time.Time, ok bool, err error) {
+	err = m.db.QueryRowContext(ctx, `
+        SELECT created FROM orders
+        WHERE customer_id = ? AND status <> 'cancelled'
+        ORDER BY created DESC
+        LIMIT 1
+    `, customerID).Scan(&last)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return last, true, nil
+}
+
+// activeOrderStatuses is every order status except cancelled
+var activeOrderStatuses = []string{
+	string(models.OrderStatusNew),
+	string(models.OrderStatusInvoiced),
+	string(models.OrderStatusReleased),
+	string(models.OrderStatusPartFulfilled),
+	string(models.OrderStatusPreorder),
+	string(models.OrderStatusFulfilled),
+	string(models.OrderStatusStandingOrder),
+}
+
+// LastOrder returns the customer's most recent non-cancelled order with its
+// lines, or nil if the mirror has none
+func (m *Mirror) LastOrder(ctx context.Context, customerID string) (*models.Order, error) {
+	page, err := m.ListOrders(ctx, OrderQuery{
+		CustomerID: customerID,
+		Statuses:   activeOrderStatuses,
+		Sort:       "-created",
+		Limit:      1,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("loading last order for %s: %w", customerID, err)
+	}
+	if len(page.Orders) == 0 {
+		return nil, nil
+	}
+	return &page.Orders[0], nil
+}
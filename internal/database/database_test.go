@@ -0,0 +1,35 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// openTestDB opens a private in-memory database without any schema
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	db, err := Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection to a shared-cache memory database sees the same data,
+	// but pragmas are per connection, so keep to the one Open configured
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// migratedTestDB opens a private in-memory database with every migration
+// applied
+func migratedTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db := openTestDB(t)
+	if _, err := Migrate(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
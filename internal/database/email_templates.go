@@ -0,0 +1,73 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/DukeRupert/rr/internal/models"
+)
+
+// EmailTemplates stores the editable email templates
+type EmailTemplates struct {
+	db *sql.DB
+}
+
+func NewEmailTemplates(db *sql.DB) *EmailTemplates {
+	return &EmailTemplates{db: db}
+}
+
+const templateColumns = `name, description, subject, html_body, text_body, updated_at`
+
+func scanTemplate(row rowScanner) (models.EmailTemplate, error) {
+	var t models.EmailTemplate
+	err := row.Scan(&t.Name, &t.Description, &t.Subject, &t.HtmlBody, &t.TextBody, &t.UpdatedAt)
+	return t, err
+}
+
+// List returns every template
+func (r *EmailTemplates) List(ctx context.Context) ([]models.EmailTemplate, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+templateColumns+` FROM email_templates ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("listing email templates: %w", err)
+	}
+	defer rows.Close()
+
+	templates := []models.EmailTemplate{}
+	for rows.Next() {
+		t, err := scanTemplate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning email template: %w", err)
+		}
+		templates = append(templates, t)
+	}
+	return templates, rows.Err()
+}
+
+// Get returns one template, or sql.ErrNoRows if it doesn't exist
+func (r *EmailTemplates) Get(ctx context.Context, name string) (*models.EmailTemplate, error) {
+	t, err := scanTemplate(r.db.QueryRowContext(ctx,
+		`SELECT `+templateColumns+` FROM email_templates WHERE name = ?`, name))
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Update replaces a template's subject and bodies. Templates can't be
+// created or renamed since the code refers to them by name. It returns
+// sql.ErrNoRows if the template doesn't exist.
+func (r *EmailTemplates) Update(ctx context.Context, t models.EmailTemplate) (*models.EmailTemplate, error) {
+	res, err := r.db.ExecContext(ctx, `
+        UPDATE email_templates SET
+            subject = ?, html_body = ?, text_body = ?, updated_at = CURRENT_TIMESTAMP
+        WHERE name = ?
+    `, t.Subject, t.HtmlBody, t.TextBody, t.Name)
+	if err != nil {
+		return nil, fmt.Errorf("updating email template %s: %w", t.Name, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return r.Get(ctx, t.Name)
+}
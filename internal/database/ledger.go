@@ -0,0 +1,79 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ReminderLedger tracks which customers have already been emailed for a
+// campaign and delivery week, so re-runs never send twice
+type ReminderLedger struct {
+	db *sql.DB
+}
+
+func NewReminderLedger(db *sql.DB) *ReminderLedger {
+	return &ReminderLedger{db: db}
+}
+
+// Lookup returns the run that claimed the customer for this campaign and
+// week, if any
+func (l *ReminderLedger) Lookup(ctx context.Context, campaign, week, customerID string) (runID int64, found bool, err error) {
+	err = l.db.QueryRowContext(ctx, `
+        SELECT run_id FROM reminder_ledger
+        WHERE campaign = ? AND delivery_week = ? AND customer_id = ?
+    `, campaign, week, customerID).Scan(&runID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("checking ledger for %s: %w", customerID, err)
+	}
+	return runID, true, nil
+}
+
+// Claim reserves the customer for runID before sending. When another run
+// already holds the claim it returns false and that run's ID. A claim left
+// in 'sending' by a crash stays taken: a missed email is better than a
+// duplicate.
+func (l *ReminderLedger) Claim(ctx context.Context, campaign, week, customerID string, runID int64) (claimed bool, heldBy int64, err error) {
+	res, err := l.db.ExecContext(ctx, `
+        INSERT INTO reminder_ledger (campaign, delivery_week, customer_id, run_id, status)
+        VALUES (?, ?, ?, ?, 'sending')
+        ON CONFLICT (campaign, delivery_week, customer_id) DO NOTHING
+    `, campaign, week, customerID, runID)
+	if err != nil {
+		return false, 0, fmt.Errorf("claiming ledger for %s: %w", customerID, err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 1 {
+		return err == nil, runID, err
+	}
+
+	heldBy, _, err = l.Lookup(ctx, campaign, week, customerID)
+	return false, heldBy, err
+}
+
+// MarkSent records that runID's email to the customer went out
+func (l *ReminderLedger) MarkSent(ctx context.Context, campaign, week, customerID string, runID int64) error {
+	_, err := l.db.ExecContext(ctx, `
+        UPDATE reminder_ledger SET status = 'sent', updated_at = CURRENT_TIMESTAMP
+        WHERE campaign = ? AND delivery_week = ? AND customer_id = ? AND run_id = ?
+    `, campaign, week, customerID, runID)
+	if err != nil {
+		return fmt.Errorf("marking %s sent: %w", customerID, err)
+	}
+	return nil
+}
+
+// Release drops runID's claim after a failed send so a later run retries
+func (l *ReminderLedger) Release(ctx context.Context, campaign, week, customerID string, runID int64) error {
+	_, err := l.db.ExecContext(ctx, `
+        DELETE FROM reminder_ledger
+        WHERE campaign = ? AND delivery_week = ? AND customer_id = ? AND run_id = ? AND status = 'sending'
+    `, campaign, week, customerID, runID)
+	if err != nil {
+		return fmt.Errorf("releasing claim for %s: %w", customerID, err)
+	}
+	return nil
+}
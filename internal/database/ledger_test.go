@@ -0,0 +1,175 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DukeRupert/rr/internal/models"
+)
+
+const (
+	testCampaign = "order_reminder"
+	testWeek     = "2026-10-19"
+)
+
+// startTestRuns records n reminder runs and returns their IDs
+func startTestRuns(t *testing.T, runs *EmailRuns, n int) []int64 {
+	t.Helper()
+	ids := make([]int64, n)
+	for i := range ids {
+		id, err := runs.Start(context.Background(), models.EmailRun{
+			Kind:         models.RunKindOrderReminder,
+			Trigger:      models.RunTriggerManual,
+			Campaign:     testCampaign,
+			DeliveryWeek: testWeek,
+			Category:     models.NotificationOrderReminders,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = id
+	}
+	return ids
+}
+
+// ledgerStep is one call against the ledger and what it should report
+type ledgerStep struct {
+	op       string // claim, sent or release
+	run      int    // index into the test's runs
+	week     string // defaults to testWeek
+	customer string // defaults to "c1"
+
+	wantClaimed bool
+	wantHeldBy  int // index of the run holding the claim after a claim
+}
+
+func TestReminderLedger(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []ledgerStep
+		// wantHolder is the index of the run holding c1 for testWeek at the
+		// end, or -1 if nobody does
+		wantHolder int
+	}{
+		{
+			name:       "first claim wins",
+			steps:      []ledgerStep{{op: "claim", run: 0, wantClaimed: true, wantHeldBy: 0}},
+			wantHolder: 0,
+		},
+		{
+			name: "second run is refused while the first is sending",
+			steps: []ledgerStep{
+				{op: "claim", run: 0, wantClaimed: true, wantHeldBy: 0},
+				{op: "claim", run: 1, wantClaimed: false, wantHeldBy: 0},
+			},
+			wantHolder: 0,
+		},
+		{
+			name: "re-run of a sent week is refused",
+			steps: []ledgerStep{
+				{op: "claim", run: 0, wantClaimed: true, wantHeldBy: 0},
+				{op: "sent", run: 0},
+				{op: "claim", run: 1, wantClaimed: false, wantHeldBy: 0},
+			},
+			wantHolder: 0,
+		},
+		{
+			name: "failed send releases the claim for a retry",
+			steps: []ledgerStep{
+				{op: "claim", run: 0, wantClaimed: true, wantHeldBy: 0},
+				{op: "release", run: 0},
+				{op: "claim", run: 1, wantClaimed: true, wantHeldBy: 1},
+			},
+			wantHolder: 1,
+		},
+		{
+			name: "release can't drop a sent email",
+			steps: []ledgerStep{
+				{op: "claim", run: 0, wantClaimed: true, wantHeldBy: 0},
+				{op: "sent", run: 0},
+				{op: "release", run: 0},
+				{op: "claim", run: 1, wantClaimed: false, wantHeldBy: 0},
+			},
+			wantHolder: 0,
+		},
+		{
+			name: "release can't drop another run's claim",
+			steps: []ledgerStep{
+				{op: "claim", run: 0, wantClaimed: true, wantHeldBy: 0},
+				{op: "release", run: 1},
+			},
+			wantHolder: 0,
+		},
+		{
+			name: "mark sent by another run changes nothing",
+			steps: []ledgerStep{
+				{op: "claim", run: 0, wantClaimed: true, wantHeldBy: 0},
+				{op: "sent", run: 1},
+				{op: "release", run: 0},
+			},
+			wantHolder: -1,
+		},
+		{
+			name: "other weeks and customers are independent",
+			steps: []ledgerStep{
+				{op: "claim", run: 0, wantClaimed: true, wantHeldBy: 0},
+				{op: "claim", run: 1, week: "2026-10-26", wantClaimed: true, wantHeldBy: 1},
+				{op: "claim", run: 1, customer: "c2", wantClaimed: true, wantHeldBy: 1},
+			},
+			wantHolder: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			db := migratedTestDB(t)
+			ledger := NewReminderLedger(db)
+			runIDs := startTestRuns(t, NewEmailRuns(db), 2)
+
+			for i, step := range tt.steps {
+				week, customer := step.week, step.customer
+				if week == "" {
+					week = testWeek
+				}
+				if customer == "" {
+					customer = "c1"
+				}
+				runID := runIDs[step.run]
+
+				switch step.op {
+				case "claim":
+					claimed, heldBy, err := ledger.Claim(ctx, testCampaign, week, customer, runID)
+					if err != nil {
+						t.Fatalf("step %d: Claim() error = %v", i, err)
+					}
+					if claimed != step.wantClaimed || heldBy != runIDs[step.wantHeldBy] {
+						t.Fatalf("step %d: Claim() = %v, held by %d; want %v, held by %d",
+							i, claimed, heldBy, step.wantClaimed, runIDs[step.wantHeldBy])
+					}
+				case "sent":
+					if err := ledger.MarkSent(ctx, testCampaign, week, customer, runID); err != nil {
+						t.Fatalf("step %d: MarkSent() error = %v", i, err)
+					}
+				case "release":
+					if err := ledger.Release(ctx, testCampaign, week, customer, runID); err != nil {
+						t.Fatalf("step %d: Release() error = %v", i, err)
+					}
+				}
+			}
+
+			heldBy, found, err := ledger.Lookup(ctx, testCampaign, testWeek, "c1")
+			if err != nil {
+				t.Fatalf("Lookup() error = %v", err)
+			}
+			if tt.wantHolder < 0 {
+				if found {
+					t.Errorf("Lookup() found a claim by run %d, want none", heldBy)
+				}
+				return
+			}
+			if !found || heldBy != runIDs[tt.wantHolder] {
+				t.Errorf("Lookup() = %d, %v; want %d", heldBy, found, runIDs[tt.wantHolder])
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migration is one numbered schema change, loaded from
+// migrations/NNNN_name.sql
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// MigrationStatus reports whether a migration has been applied
+type MigrationStatus struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// loadMigrations reads the migrations directory of fsys in version order
+func loadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("reading migrations: %w", err)
+	}
+
+	var migrations []Migration
+	seen := make(map[int]string)
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+
+		base := strings.TrimSuffix(entry.Name(), ".sql")
+		prefix, name, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s must be named NNNN_name.sql", entry.Name())
+		}
+		if other, dup := seen[version]; dup {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", other, entry.Name(), version)
+		}
+		seen[version] = entry.Name()
+
+		contents, err := fs.ReadFile(fsys, path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("reading migration %s: %w", entry.Name(), err)
+		}
+
+		migrations = append(migrations, Migration{Version: version, Name: name, SQL: string(contents)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+func ensureMigrationsTable(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, `
+        CREATE TABLE IF NOT EXISTS schema_migrations (
+            version INTEGER PRIMARY KEY,
+            name TEXT NOT NULL,
+            applied_at DATETIME NOT NULL
+        )
+    `)
+	return err
+}
+
+func appliedMigrations(ctx context.Context, db *sql.DB) (map[int]time.Time, error) {
+	rows, err := db.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("reading applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// Migrate applies every pending migration in order, each in its own
+// transaction, and returns the migrations it applied
+func Migrate(ctx context.Context, db *sql.DB) ([]Migration, error) {
+	return migrate(ctx, db, migrationFiles)
+}
+
+func migrate(ctx context.Context, db *sql.DB, fsys fs.FS) ([]Migration, error) {
+	migrations, err := loadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureMigrationsTable(ctx, db); err != nil {
+		return nil, fmt.Errorf("creating schema_migrations: %w", err)
+	}
+	applied, err := appliedMigrations(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	var ran []Migration
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		if err := applyMigration(ctx, db, m); err != nil {
+			return ran, err
+		}
+		ran = append(ran, m)
+	}
+	return ran, nil
+}
+
+func applyMigration(ctx context.Context, db *sql.DB, m Migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("starting migration %04d: %w", m.Version, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, m.SQL); err != nil {
+		return fmt.Errorf("applying migration %04d_%s: %w", m.Version, m.Name, err)
+	}
+	_, err = tx.ExecContext(ctx, `
+        INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)
+    `, m.Version, m.Name, time.Now())
+	if err != nil {
+		return fmt.Errorf("recording migration %04d: %w", m.Version, err)
+	}
+
+	return tx.Commit()
+}
+
+// Status lists every known migration and when it was applied, if it was
+func Status(ctx context.Context, db *sql.DB) ([]MigrationStatus, error) {
+	return status(ctx, db, migrationFiles)
+}
+
+func status(ctx context.Context, db *sql.DB, fsys fs.FS) ([]MigrationStatus, error) {
+	migrations, err := loadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureMigrationsTable(ctx, db); err != nil {
+		return nil, fmt.Errorf("creating schema_migrations: %w", err)
+	}
+	applied, err := appliedMigrations(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		status := MigrationStatus{Version: m.Version, Name: m.Name}
+		if at, ok := applied[m.Version]; ok {
+			status.AppliedAt = &at
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
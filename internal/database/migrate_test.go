@@ -0,0 +1,155 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    fstest.MapFS
+		wantErr  string
+		wantVers []int
+	}{
+		{
+			name: "sorted by version",
+			files: fstest.MapFS{
+				"migrations/0002_second.sql": {Data: []byte("SELECT 2;")},
+				"migrations/0010_tenth.sql":  {Data: []byte("SELECT 10;")},
+				"migrations/0001_first.sql":  {Data: []byte("SELECT 1;")},
+				"migrations/README.md":       {Data: []byte("not a migration")},
+			},
+			wantVers: []int{1, 2, 10},
+		},
+		{
+			name:    "missing version",
+			files:   fstest.MapFS{"migrations/first.sql": {Data: []byte("SELECT 1;")}},
+			wantErr: "must be named NNNN_name.sql",
+		},
+		{
+			name:    "version zero",
+			files:   fstest.MapFS{"migrations/0000_zero.sql": {Data: []byte("SELECT 1;")}},
+			wantErr: "must be named NNNN_name.sql",
+		},
+		{
+			name: "duplicate version",
+			files: fstest.MapFS{
+				"migrations/0001_first.sql": {Data: []byte("SELECT 1;")},
+				"migrations/0001_again.sql": {Data: []byte("SELECT 1;")},
+			},
+			wantErr: "share version 1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := loadMigrations(tt.files)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("loadMigrations() error = %v, want it to mention %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadMigrations() error = %v", err)
+			}
+			var got []int
+			for _, m := range migrations {
+				got = append(got, m.Version)
+			}
+			if len(got) != len(tt.wantVers) {
+				t.Fatalf("versions = %v, want %v", got, tt.wantVers)
+			}
+			for i := range got {
+				if got[i] != tt.wantVers[i] {
+					t.Fatalf("versions = %v, want %v", got, tt.wantVers)
+				}
+			}
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+
+	ran, err := Migrate(ctx, db)
+	if err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	for i, m := range ran {
+		if m.Version != i+1 {
+			t.Fatalf("migration %d has version %d; versions should run 1, 2, 3...", i, m.Version)
+		}
+	}
+
+	again, err := Migrate(ctx, db)
+	if err != nil || len(again) != 0 {
+		t.Fatalf("second Migrate() = %d migrations, %v; want none", len(again), err)
+	}
+
+	statuses, err := Status(ctx, db)
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	if len(statuses) != len(ran) {
+		t.Fatalf("Status() lists %d migrations, want %d", len(statuses), len(ran))
+	}
+	for _, s := range statuses {
+		if s.AppliedAt == nil {
+			t.Errorf("migration %04d_%s not marked applied", s.Version, s.Name)
+		}
+	}
+}
+
+func TestMigrateStopsAtFailure(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	files := fstest.MapFS{
+		"migrations/0001_widgets.sql": {Data: []byte("CREATE TABLE widgets (id INTEGER PRIMARY KEY);")},
+		"migrations/0002_broken.sql":  {Data: []byte("CREATE TABLE gadgets (id INTEGER PRIMARY KEY); INSERT INTO nowhere VALUES (1);")},
+		"migrations/0003_later.sql":   {Data: []byte("CREATE TABLE later (id INTEGER PRIMARY KEY);")},
+	}
+
+	ran, err := migrate(ctx, db, files)
+	if err == nil || !strings.Contains(err.Error(), "0002_broken") {
+		t.Fatalf("migrate() error = %v, want it to name 0002_broken", err)
+	}
+	if len(ran) != 1 || ran[0].Version != 1 {
+		t.Fatalf("migrate() ran %v, want only 0001", ran)
+	}
+
+	// The failed migration is rolled back as a whole and nothing after it runs
+	for table, want := range map[string]bool{"widgets": true, "gadgets": false, "later": false} {
+		var exists bool
+		err := db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = ?)`, table).Scan(&exists)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exists != want {
+			t.Errorf("table %s exists = %v, want %v", table, exists, want)
+		}
+	}
+
+	statuses, err := status(ctx, db, files)
+	if err != nil {
+		t.Fatalf("status() error = %v", err)
+	}
+	for _, s := range statuses {
+		if applied := s.AppliedAt != nil; applied != (s.Version == 1) {
+			t.Errorf("migration %04d applied = %v", s.Version, applied)
+		}
+	}
+
+	// Once fixed, the next run picks up where the last one stopped
+	files["migrations/0002_broken.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE gadgets (id INTEGER PRIMARY KEY);")}
+	ran, err = migrate(ctx, db, files)
+	if err != nil {
+		t.Fatalf("migrate() after fix error = %v", err)
+	}
+	if len(ran) != 2 || ran[0].Version != 2 || ran[1].Version != 3 {
+		t.Fatalf("migrate() after fix ran %v, want 0002 and 0003", ran)
+	}
+}
@@ -0,0 +1,120 @@
+-- Baseline schema. Tables use IF NOT EXISTS so databases created before
+-- versioned migrations existed adopt it without losing data.
+
+CREATE TABLE IF NOT EXISTS customers (
+    id TEXT PRIMARY KEY,
+    company_name TEXT NOT NULL,
+    created_at DATETIME NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('new', 'active', 'closed')),
+    reference TEXT,
+    internal_note TEXT,
+    phone TEXT,
+    tax_number TEXT,
+    tax_rate_id TEXT,
+    minimum_spend REAL,
+    payment_terms_id TEXT,
+    customer_group_id TEXT,
+    price_list_id TEXT,
+    order_interval INTEGER CHECK (order_interval IN (1,2,3,4)),
+    email_addresses TEXT NOT NULL, -- JSON object
+    buyers TEXT NOT NULL -- JSON array
+);
+
+CREATE TABLE IF NOT EXISTS addresses (
+    id TEXT PRIMARY KEY,
+    customer_id TEXT NOT NULL,
+    type TEXT NOT NULL CHECK (type IN ('shipping', 'billing')),
+    company_name TEXT,
+    contact_name TEXT,
+    line1 TEXT NOT NULL,
+    line2 TEXT,
+    city TEXT NOT NULL,
+    state TEXT,
+    postal_code TEXT NOT NULL,
+    country TEXT NOT NULL,
+    FOREIGN KEY (customer_id) REFERENCES customers(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS orders (
+    id TEXT PRIMARY KEY,
+    number INTEGER NOT NULL UNIQUE,
+    created DATETIME NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('new', 'invoiced', 'released', 'part_fulfilled', 'preorder', 'fulfilled', 'standing_order', 'cancelled')),
+    customer_id TEXT NOT NULL,
+    company_name TEXT NOT NULL,
+    phone TEXT,
+    email_addresses TEXT NOT NULL, -- JSON object
+    created_by TEXT NOT NULL,
+    delivery_date DATETIME NOT NULL,
+    reference TEXT,
+    internal_note TEXT,
+    customer_po_number TEXT,
+    customer_note TEXT,
+    standing_order_id TEXT,
+    shipping_type TEXT,
+    shipping_address_id TEXT,
+    billing_address_id TEXT,
+    currency TEXT NOT NULL,
+    net_total REAL NOT NULL,
+    gross_total REAL NOT NULL,
+    FOREIGN KEY (customer_id) REFERENCES customers(id),
+    FOREIGN KEY (shipping_address_id) REFERENCES addresses(id),
+    FOREIGN KEY (billing_address_id) REFERENCES addresses(id)
+);
+
+CREATE TABLE IF NOT EXISTS order_lines (
+    id TEXT PRIMARY KEY,
+    order_id TEXT NOT NULL,
+    sku TEXT NOT NULL,
+    name TEXT NOT NULL,
+    options TEXT,
+    grouping_category_id TEXT,
+    grouping_category_name TEXT,
+    shipping BOOLEAN NOT NULL DEFAULT 0,
+    quantity INTEGER NOT NULL,
+    unit_price REAL NOT NULL,
+    sub_total REAL NOT NULL,
+    tax_rate_id TEXT NOT NULL,
+    tax_name TEXT NOT NULL,
+    tax_rate REAL NOT NULL,
+    tax_amount REAL NOT NULL,
+    preorder_window_id TEXT,
+    on_hold BOOLEAN NOT NULL DEFAULT 0,
+    invoiced INTEGER NOT NULL DEFAULT 0,
+    paid INTEGER NOT NULL DEFAULT 0,
+    dispatched INTEGER NOT NULL DEFAULT 0,
+    FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS customer_notifications (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    customer_id TEXT UNIQUE NOT NULL,
+    email_notify_days BOOLEAN NOT NULL DEFAULT true,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (customer_id) REFERENCES customers(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS sync_state (
+    resource TEXT PRIMARY KEY,
+    synced_through DATETIME NOT NULL,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_customers_status ON customers(status);
+CREATE INDEX IF NOT EXISTS idx_orders_customer_id ON orders(customer_id);
+CREATE INDEX IF NOT EXISTS idx_orders_status ON orders(status);
+CREATE INDEX IF NOT EXISTS idx_orders_delivery_date ON orders(delivery_date);
+CREATE INDEX IF NOT EXISTS idx_order_lines_order_id ON order_lines(order_id);
+CREATE INDEX IF NOT EXISTS idx_addresses_customer_id ON addresses(customer_id);
+CREATE INDEX IF NOT EXISTS idx_customer_notifications_customer_id ON customer_notifications(customer_id);
+
+-- Tokens were created by the Orderspace client itself, and older tables lack
+-- expires_at. They are a disposable cache, so rebuild rather than alter.
+DROP TABLE IF EXISTS tokens;
+CREATE TABLE tokens (
+    id INTEGER PRIMARY KEY,
+    access_token TEXT NOT NULL,
+    created_at DATETIME NOT NULL,
+    expires_at DATETIME
+);
@@ -0,0 +1,16 @@
+-- Per-category, per-address email preferences. customer_notifications
+-- .email_notify_days stays as the customer-wide switch; these rows refine it.
+-- An empty email_address applies to every address on the customer.
+CREATE TABLE notification_preferences (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    customer_id TEXT NOT NULL,
+    category TEXT NOT NULL CHECK (category IN ('order_reminders', 'announcements', 'dispatch_notices', 'invoices')),
+    email_address TEXT NOT NULL DEFAULT '',
+    enabled BOOLEAN NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (customer_id, category, email_address),
+    FOREIGN KEY (customer_id) REFERENCES customers(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_notification_preferences_customer_id ON notification_preferences(customer_id);
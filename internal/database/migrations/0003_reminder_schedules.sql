@@ -0,0 +1,19 @@
+-- When order reminders go out. A schedule is either a standard five-field
+-- cron expression or a weekday (0 = Sunday) and HH:MM time, evaluated in
+-- its timezone.
+CREATE TABLE reminder_schedules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    cron_expression TEXT NOT NULL DEFAULT '',
+    weekday INTEGER CHECK (weekday BETWEEN 0 AND 6),
+    time_of_day TEXT NOT NULL DEFAULT '',
+    timezone TEXT NOT NULL DEFAULT 'America/Denver',
+    enabled BOOLEAN NOT NULL DEFAULT 1,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    CHECK (cron_expression <> '' OR (weekday IS NOT NULL AND time_of_day <> ''))
+);
+
+-- The schedule that used to be hard-coded
+INSERT INTO reminder_schedules (name, weekday, time_of_day, timezone)
+VALUES ('Weekly reminder', 5, '10:00', 'America/Denver');
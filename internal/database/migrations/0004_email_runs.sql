@@ -0,0 +1,38 @@
+-- One row per bulk send, scheduled reminders and ad-hoc emails alike
+CREATE TABLE reminder_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    kind TEXT NOT NULL CHECK (kind IN ('order_reminder', 'adhoc')),
+    trigger TEXT NOT NULL CHECK (trigger IN ('scheduled', 'manual')),
+    schedule_id INTEGER,
+    category TEXT NOT NULL,
+    subject TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'running' CHECK (status IN ('running', 'completed', 'failed')),
+    error TEXT NOT NULL DEFAULT '',
+    sent INTEGER NOT NULL DEFAULT 0,
+    failed INTEGER NOT NULL DEFAULT 0,
+    skipped INTEGER NOT NULL DEFAULT 0,
+    started_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at DATETIME,
+    FOREIGN KEY (schedule_id) REFERENCES reminder_schedules(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_reminder_runs_started_at ON reminder_runs(started_at);
+
+-- The outcome for each customer in a run. customer_id isn't a foreign key
+-- because customers are emailed straight from Orderspace and may not be
+-- mirrored yet.
+CREATE TABLE sent_emails (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    run_id INTEGER NOT NULL,
+    customer_id TEXT NOT NULL,
+    company_name TEXT NOT NULL,
+    email_address TEXT NOT NULL DEFAULT '',
+    message_id TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL CHECK (status IN ('sent', 'failed', 'skipped')),
+    error TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (run_id) REFERENCES reminder_runs(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_sent_emails_run_id ON sent_emails(run_id);
+CREATE INDEX idx_sent_emails_customer_id ON sent_emails(customer_id);
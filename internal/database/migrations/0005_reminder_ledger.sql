@@ -0,0 +1,21 @@
+-- Reminder runs are keyed by campaign and the delivery week they ask
+-- customers to order for, so re-runs for the same week can be recognised
+ALTER TABLE reminder_runs ADD COLUMN campaign TEXT NOT NULL DEFAULT '';
+ALTER TABLE reminder_runs ADD COLUMN delivery_week TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX idx_reminder_runs_campaign ON reminder_runs(campaign, delivery_week);
+
+-- Which customers have been emailed for each campaign and week. A row is
+-- claimed as 'sending' before the email goes out and becomes 'sent' once it
+-- has; failed sends delete their claim so a later run retries them.
+CREATE TABLE reminder_ledger (
+    campaign TEXT NOT NULL,
+    delivery_week TEXT NOT NULL,
+    customer_id TEXT NOT NULL,
+    run_id INTEGER NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('sending', 'sent')),
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (campaign, delivery_week, customer_id),
+    FOREIGN KEY (run_id) REFERENCES reminder_runs(id)
+);
@@ -0,0 +1,56 @@
+-- Editable email templates. 'layout' wraps every email and includes the
+-- content template with {{template "content" .}}. See internal/templates
+-- for the variables templates can use.
+CREATE TABLE email_templates (
+    name TEXT PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT '',
+    subject TEXT NOT NULL DEFAULT '',
+    html_body TEXT NOT NULL,
+    text_body TEXT NOT NULL,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO email_templates (name, description, html_body, text_body) VALUES (
+    'layout',
+    'Shared layout for every customer email',
+    '<html>
+    <body>
+        {{template "content" .}}
+        <p style="font-size:12px;color:#888888;">Don''t want these emails? <a href="{{.UnsubscribeURL}}">Unsubscribe</a>.</p>
+    </body>
+</html>
+',
+    '{{template "content" .}}
+
+--
+Don''t want these emails? Unsubscribe: {{.UnsubscribeURL}}
+'
+);
+
+INSERT INTO email_templates (name, description, subject, html_body, text_body) VALUES (
+    'order_reminder',
+    'Weekly order reminder',
+    'Time to Place Your Coffee Order!',
+    '<h2>Hey {{.CompanyName}}!</h2>
+        <p>Just a friendly reminder from your coffee crew at Rockabilly Roasting over here in Washington State.</p>
+        <p>To keep your coffee delivery running smooth as a ''57 Chevy, we kindly ask that you place your order by the afternoon of <strong>{{.OrderCutoff}}</strong>. This helps us make sure your beans arrive right on schedule the week of {{.NextDeliveryDate}}.</p>
+        {{if .LastOrderSummary}}<p>For reference, your last order was {{.LastOrderSummary}}.</p>{{end}}
+        <p><a href="https://rockabillyroasting.orderspace.com/">Click here to place your order now!</a></p>
+        <p>Need anything else? Just hit reply - we''re always happy to help!</p>
+        <p>Keep rockin'',<br>
+        The Rockabilly Roasting Team</p>',
+    'Hey {{.CompanyName}}!
+
+Just a friendly reminder from your coffee crew at Rockabilly Roasting over here in Washington State.
+
+To keep your coffee delivery running smooth as a ''57 Chevy, we kindly ask that you place your order by the afternoon of {{.OrderCutoff}}. This helps us make sure your beans arrive right on schedule the week of {{.NextDeliveryDate}}.
+{{if .LastOrderSummary}}
+For reference, your last order was {{.LastOrderSummary}}.
+{{end}}
+Place your order here: https://rockabillyroasting.orderspace.com/
+
+Need anything else? Just hit reply - we''re always happy to help!
+
+Keep rockin'',
+The Rockabilly Roasting Team'
+);
@@ -0,0 +1,235 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DukeRupert/rr/internal/models"
+)
+
+// Mirror writes Orderspace customers and orders into the local tables so
+// they can be queried without a live API call
+type Mirror struct {
+	db *sql.DB
+}
+
+func NewMirror(db *sql.DB) *Mirror {
+	return &Mirror{db: db}
+}
+
+// customerAddressID and orderAddressID derive stable address keys, since
+// Orderspace addresses are embedded in their parent and carry no ID
+func customerAddressID(customerID string, i int) string {
+	return fmt.Sprintf("customer:%s:%d", customerID, i)
+}
+
+func orderAddressID(orderID, kind string) string {
+	return fmt.Sprintf("order:%s:%s", orderID, kind)
+}
+
+// HasCustomer reports whether the customer is already mirrored
+func (m *Mirror) HasCustomer(ctx context.Context, id string) (bool, error) {
+	var exists bool
+	err := m.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM customers WHERE id = ?)`, id).Scan(&exists)
+	return exists, err
+}
+
+// UpsertCustomer inserts or refreshes a customer and replaces its
+// addresses. The app-owned order_interval column is left untouched.
+func (m *Mirror) UpsertCustomer(ctx context.Context, customer models.Customer) error {
+	emails, err := json.Marshal(customer.EmailAddresses)
+	if err != nil {
+		return fmt.Errorf("encoding email addresses: %w", err)
+	}
+	buyers := customer.Buyers
+	if buyers == nil {
+		buyers = []models.Buyer{}
+	}
+	buyersJSON, err := json.Marshal(buyers)
+	if err != nil {
+		return fmt.Errorf("encoding buyers: %w", err)
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+        INSERT INTO customers (
+            id, company_name, created_at, status, reference, internal_note, phone,
+            tax_number, tax_rate_id, minimum_spend, payment_terms_id, customer_group_id,
+            price_list_id, email_addresses, buyers
+        ) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+        ON CONFLICT(id) DO UPDATE SET
+            company_name = excluded.company_name,
+            created_at = excluded.created_at,
+            status = excluded.status,
+            reference = excluded.reference,
+            internal_note = excluded.internal_note,
+            phone = excluded.phone,
+            tax_number = excluded.tax_number,
+            tax_rate_id = excluded.tax_rate_id,
+            minimum_spend = excluded.minimum_spend,
+            payment_terms_id = excluded.payment_terms_id,
+            customer_group_id = excluded.customer_group_id,
+            price_list_id = excluded.price_list_id,
+            email_addresses = excluded.email_addresses,
+            buyers = excluded.buyers
+    `, customer.ID, customer.CompanyName, customer.CreatedAt, customer.Status, customer.Reference,
+		customer.InternalNote, customer.Phone, customer.TaxNumber, customer.TaxRateID, customer.MinimumSpend,
+		customer.PaymentTermsID, customer.CustomerGroupID, customer.PriceListID, string(emails), string(buyersJSON))
+	if err != nil {
+		return fmt.Errorf("upserting customer %s: %w", customer.ID, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+        DELETE FROM addresses WHERE customer_id = ? AND id LIKE 'customer:%'
+    `, customer.ID)
+	if err != nil {
+		return fmt.Errorf("clearing addresses for customer %s: %w", customer.ID, err)
+	}
+
+	for i, address := range customer.Addresses {
+		if err := upsertAddress(ctx, tx, customerAddressID(customer.ID, i), customer.ID, "shipping", address); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UpsertOrder inserts or refreshes an order together with its addresses and
+// lines. Lines no longer on the order are removed.
+func (m *Mirror) UpsertOrder(ctx context.Context, order models.Order) error {
+	emails, err := json.Marshal(order.EmailAddresses)
+	if err != nil {
+		return fmt.Errorf("encoding email addresses: %w", err)
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	shippingID := orderAddressID(order.ID, "shipping")
+	billingID := orderAddressID(order.ID, "billing")
+	if err := upsertAddress(ctx, tx, shippingID, order.CustomerID, "shipping", order.ShippingAddress); err != nil {
+		return err
+	}
+	if err := upsertAddress(ctx, tx, billingID, order.CustomerID, "billing", order.BillingAddress); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+        INSERT INTO orders (
+            id, number, created, status, customer_id, company_name, phone, email_addresses,
+            created_by, delivery_date, reference, internal_note, customer_po_number,
+            customer_note, standing_order_id, shipping_type, shipping_address_id,
+            billing_address_id, currency, net_total, gross_total
+        ) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+        ON CONFLICT(id) DO UPDATE SET
+            number = excluded.number,
+            created = excluded.created,
+            status = excluded.status,
+            customer_id = excluded.customer_id,
+            company_name = excluded.company_name,
+            phone = excluded.phone,
+            email_addresses = excluded.email_addresses,
+            created_by = excluded.created_by,
+            delivery_date = excluded.delivery_date,
+            reference = excluded.reference,
+            internal_note = excluded.internal_note,
+            customer_po_number = excluded.customer_po_number,
+            customer_note = excluded.customer_note,
+            standing_order_id = excluded.standing_order_id,
+            shipping_type = excluded.shipping_type,
+            shipping_address_id = excluded.shipping_address_id,
+            billing_address_id = excluded.billing_address_id,
+            currency = excluded.currency,
+            net_total = excluded.net_total,
+            gross_total = excluded.gross_total
+    `, order.ID, order.Number, order.Created, order.Status, order.CustomerID, order.CompanyName,
+		order.Phone, string(emails), order.CreatedBy, order.DeliveryDate, order.Reference,
+		order.InternalNote, order.CustomerPONumber, order.CustomerNote, order.StandingOrderID,
+		order.ShippingType, shippingID, billingID, order.Currency, order.NetTotal, order.GrossTotal)
+	if err != nil {
+		return fmt.Errorf("upserting order %s: %w", order.ID, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM order_lines WHERE order_id = ?`, order.ID); err != nil {
+		return fmt.Errorf("clearing lines for order %s: %w", order.ID, err)
+	}
+
+	for _, line := range order.OrderLines {
+		_, err = tx.ExecContext(ctx, `
+            INSERT INTO order_lines (
+                id, order_id, sku, name, options, grouping_category_id, grouping_category_name,
+                shipping, quantity, unit_price, sub_total, tax_rate_id, tax_name, tax_rate,
+                tax_amount, preorder_window_id, on_hold, invoiced, paid, dispatched
+            ) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+        `, line.ID, order.ID, line.SKU, line.Name, line.Options, line.GroupingCategory.ID,
+			line.GroupingCategory.Name, line.Shipping, line.Quantity, line.UnitPrice, line.SubTotal,
+			line.TaxRateID, line.TaxName, line.TaxRate, line.TaxAmount, line.PreorderWindowID,
+			line.OnHold, line.Invoiced, line.Paid, line.Dispatched)
+		if err != nil {
+			return fmt.Errorf("inserting line %s for order %s: %w", line.ID, order.ID, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func upsertAddress(ctx context.Context, tx *sql.Tx, id, customerID, kind string, address models.Address) error {
+	_, err := tx.ExecContext(ctx, `
+        INSERT INTO addresses (
+            id, customer_id, type, company_name, contact_name, line1, line2, city, state, postal_code, country
+        ) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+        ON CONFLICT(id) DO UPDATE SET
+            customer_id = excluded.customer_id,
+            company_name = excluded.company_name,
+            contact_name = excluded.contact_name,
+            line1 = excluded.line1,
+            line2 = excluded.line2,
+            city = excluded.city,
+            state = excluded.state,
+            postal_code = excluded.postal_code,
+            country = excluded.country
+    `, id, customerID, kind, address.CompanyName, address.ContactName, address.Line1, address.Line2,
+		address.City, address.State, address.PostalCode, address.Country)
+	if err != nil {
+		return fmt.Errorf("upserting address %s: %w", id, err)
+	}
+	return nil
+}
+
+// SyncedThrough returns the high-water mark recorded for a resource, or the
+// zero time if it has never been synced
+func (m *Mirror) SyncedThrough(ctx context.Context, resource string) (time.Time, error) {
+	var mark time.Time
+	err := m.db.QueryRowContext(ctx, `
+        SELECT synced_through FROM sync_state WHERE resource = ?
+    `, resource).Scan(&mark)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	return mark, err
+}
+
+// SetSyncedThrough records the high-water mark for a resource
+func (m *Mirror) SetSyncedThrough(ctx context.Context, resource string, mark time.Time) error {
+	_, err := m.db.ExecContext(ctx, `
+        INSERT INTO sync_state (resource, synced_through, updated_at)
+        VALUES (?, ?, CURRENT_TIMESTAMP)
+        ON CONFLICT(resource) DO UPDATE SET
+            synced_through = excluded.synced_through,
+            updated_at = CURRENT_TIMESTAMP
+    `, resource, mark)
+	return err
+}
@@ -0,0 +1,455 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/DukeRupert/rr/internal/models"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// ErrInvalidQuery is returned for listing options the mirror can't honour,
+// such as an unknown sort column
+var ErrInvalidQuery = errors.New("invalid query")
+
+// customerSortColumns and orderSortColumns whitelist the sortable columns,
+// keyed by the name callers pass in ?sort=
+var customerSortColumns = map[string]string{
+	"company_name": "c.company_name",
+	"created_at":   "c.created_at",
+	"reference":    "c.reference",
+	"status":       "c.status",
+}
+
+var orderSortColumns = map[string]string{
+	"number":        "o.number",
+	"created":       "o.created",
+	"delivery_date": "o.delivery_date",
+	"company_name":  "o.company_name",
+	"gross_total":   "o.gross_total",
+}
+
+// CustomerQuery filters and pages mirrored customers
+type CustomerQuery struct {
+	// Search matches company name or reference, case-insensitively
+	Search   string
+	Statuses []string
+	// Sort is a column name, prefixed with "-" for descending
+	Sort          string
+	Limit         int
+	Offset        int
+	StartingAfter string
+}
+
+// CustomerPage is one page of mirrored customers
+type CustomerPage struct {
+	Customers []models.Customer `json:"customers"`
+	Total     int               `json:"total"`
+	HasMore   bool              `json:"has_more"`
+}
+
+// OrderQuery filters and pages mirrored orders
+type OrderQuery struct {
+	// Search matches company name, reference or customer PO number
+	Search             string
+	Statuses           []string
+	CustomerID         string
+	StandingOrderID    string
+	Number             int
+	CreatedSince       string
+	CreatedBefore      string
+	DeliveryDateSince  string
+	DeliveryDateBefore string
+	// Sort is a column name, prefixed with "-" for descending
+	Sort          string
+	Limit         int
+	Offset        int
+	StartingAfter string
+}
+
+// OrderPage is one page of mirrored orders
+type OrderPage struct {
+	Orders  []models.Order `json:"orders"`
+	Total   int            `json:"total"`
+	HasMore bool           `json:"has_more"`
+}
+
+// pageQuery accumulates the WHERE clause and arguments for a listing
+type pageQuery struct {
+	where []string
+	args  []interface{}
+}
+
+func (q *pageQuery) add(clause string, args ...interface{}) {
+	q.where = append(q.where, clause)
+	q.args = append(q.args, args...)
+}
+
+func (q *pageQuery) addIn(column string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(values)), ",")
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	q.add(fmt.Sprintf("%s IN (%s)", column, placeholders), args...)
+}
+
+func (q *pageQuery) clause() string {
+	if len(q.where) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(q.where, " AND ")
+}
+
+// resolveSort maps a ?sort= value onto a whitelisted column and direction
+func resolveSort(sort string, columns map[string]string, fallback string) (column string, desc bool, err error) {
+	if sort == "" {
+		sort = fallback
+	}
+	desc = strings.HasPrefix(sort, "-")
+	column, ok := columns[strings.TrimPrefix(sort, "-")]
+	if !ok {
+		return "", false, fmt.Errorf("%w: unsupported sort %q", ErrInvalidQuery, sort)
+	}
+	return column, desc, nil
+}
+
+// checkCursor makes sure the starting_after row exists in table. Without
+// it the keyset comparison matches nothing and the page comes back empty.
+func (m *Mirror) checkCursor(ctx context.Context, table, id string) error {
+	var exists bool
+	err := m.db.QueryRowContext(ctx, fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %s WHERE id = ?)`, table), id).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("checking cursor: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("%w: unknown starting_after %q", ErrInvalidQuery, id)
+	}
+	return nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// containsPattern is a LIKE pattern matching text anywhere, with the
+// wildcards in text escaped so they match literally. Pair it with
+// ESCAPE '\'.
+func containsPattern(text string) string {
+	return "%" + likeEscaper.Replace(text) + "%"
+}
+
+func pageSize(limit int) int {
+	if limit <= 0 {
+		return defaultPageSize
+	}
+	if limit > maxPageSize {
+		return maxPageSize
+	}
+	return limit
+}
+
+// ListCustomers returns mirrored customers matching q
+func (m *Mirror) ListCustomers(ctx context.Context, q CustomerQuery) (*CustomerPage, error) {
+	sortColumn, desc, err := resolveSort(q.Sort, customerSortColumns, "company_name")
+	if err != nil {
+		return nil, err
+	}
+
+	filter := &pageQuery{}
+	if q.Search != "" {
+		like := containsPattern(q.Search)
+		filter.add(`(c.company_name LIKE ? ESCAPE '\' OR c.reference LIKE ? ESCAPE '\')`, like, like)
+	}
+	filter.addIn("c.status", q.Statuses)
+
+	page := &CustomerPage{Customers: []models.Customer{}}
+	countSQL := "SELECT COUNT(*) FROM customers c " + filter.clause()
+	if err := m.db.QueryRowContext(ctx, countSQL, filter.args...).Scan(&page.Total); err != nil {
+		return nil, fmt.Errorf("counting customers: %w", err)
+	}
+
+	// Keyset pagination compares (sort column, id) against the cursor row
+	if q.StartingAfter != "" {
+		if err := m.checkCursor(ctx, "customers", q.StartingAfter); err != nil {
+			return nil, err
+		}
+		op := ">"
+		if desc {
+			op = "<"
+		}
+		cursorColumn := strings.TrimPrefix(sortColumn, "c.")
+		filter.add(fmt.Sprintf("(%s, c.id) %s (SELECT %s, id FROM customers WHERE id = ?)", sortColumn, op, cursorColumn), q.StartingAfter)
+	}
+
+	direction := "ASC"
+	if desc {
+		direction = "DESC"
+	}
+	limit := pageSize(q.Limit)
+	args := append(filter.args, limit+1, q.Offset)
+
+	rows, err := m.db.QueryContext(ctx, fmt.Sprintf(`
+        SELECT c.id, c.company_name, c.created_at, c.status, COALESCE(c.reference, ''),
+               COALESCE(c.internal_note, ''), COALESCE(c.phone, ''), COALESCE(c.tax_number, ''),
+               c.tax_rate_id, c.minimum_spend, c.payment_terms_id, c.customer_group_id,
+               c.price_list_id, c.order_interval, c.email_addresses, c.buyers
+        FROM customers c
+        %s
+        ORDER BY %s %s, c.id %s
+        LIMIT ? OFFSET ?
+    `, filter.clause(), sortColumn, direction, direction), args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying customers: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var customer models.Customer
+		var emails, buyers string
+		err := rows.Scan(&customer.ID, &customer.CompanyName, &customer.CreatedAt, &customer.Status,
+			&customer.Reference, &customer.InternalNote, &customer.Phone, &customer.TaxNumber,
+			&customer.TaxRateID, &customer.MinimumSpend, &customer.PaymentTermsID,
+			&customer.CustomerGroupID, &customer.PriceListID, &customer.OrderInterval, &emails, &buyers)
+		if err != nil {
+			return nil, fmt.Errorf("scanning customer: %w", err)
+		}
+		if err := json.Unmarshal([]byte(emails), &customer.EmailAddresses); err != nil {
+			return nil, fmt.Errorf("decoding email addresses for %s: %w", customer.ID, err)
+		}
+		if err := json.Unmarshal([]byte(buyers), &customer.Buyers); err != nil {
+			return nil, fmt.Errorf("decoding buyers for %s: %w", customer.ID, err)
+		}
+		page.Customers = append(page.Customers, customer)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(page.Customers) > limit {
+		page.Customers = page.Customers[:limit]
+		page.HasMore = true
+	}
+
+	for i := range page.Customers {
+		addresses, err := m.customerAddresses(ctx, page.Customers[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		page.Customers[i].Addresses = addresses
+	}
+
+	return page, nil
+}
+
+func (m *Mirror) customerAddresses(ctx context.Context, customerID string) ([]models.Address, error) {
+	rows, err := m.db.QueryContext(ctx, `
+        SELECT `+addressColumns+`
+        FROM addresses
+        WHERE customer_id = ? AND id LIKE 'customer:%'
+        ORDER BY rowid
+    `, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("querying addresses for %s: %w", customerID, err)
+	}
+	defer rows.Close()
+
+	addresses := []models.Address{}
+	for rows.Next() {
+		address, err := scanAddress(rows)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, address)
+	}
+	return addresses, rows.Err()
+}
+
+const addressColumns = `COALESCE(company_name, ''), COALESCE(contact_name, ''), line1,
+               COALESCE(line2, ''), city, COALESCE(state, ''), postal_code, country`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAddress(row scanner) (models.Address, error) {
+	var a models.Address
+	err := row.Scan(&a.CompanyName, &a.ContactName, &a.Line1, &a.Line2, &a.City, &a.State, &a.PostalCode, &a.Country)
+	if err != nil {
+		return a, fmt.Errorf("scanning address: %w", err)
+	}
+	return a, nil
+}
+
+// ListOrders returns mirrored orders matching q
+func (m *Mirror) ListOrders(ctx context.Context, q OrderQuery) (*OrderPage, error) {
+	sortColumn, desc, err := resolveSort(q.Sort, orderSortColumns, "-created")
+	if err != nil {
+		return nil, err
+	}
+
+	filter := &pageQuery{}
+	if q.Search != "" {
+		like := containsPattern(q.Search)
+		filter.add(`(o.company_name LIKE ? ESCAPE '\' OR o.reference LIKE ? ESCAPE '\' OR o.customer_po_number LIKE ? ESCAPE '\')`, like, like, like)
+	}
+	filter.addIn("o.status", q.Statuses)
+	if q.CustomerID != "" {
+		filter.add("o.customer_id = ?", q.CustomerID)
+	}
+	if q.StandingOrderID != "" {
+		filter.add("o.standing_order_id = ?", q.StandingOrderID)
+	}
+	if q.Number > 0 {
+		filter.add("o.number = ?", q.Number)
+	}
+	if q.CreatedSince != "" {
+		filter.add("datetime(o.created) >= datetime(?)", q.CreatedSince)
+	}
+	if q.CreatedBefore != "" {
+		filter.add("datetime(o.created) < datetime(?)", q.CreatedBefore)
+	}
+	if q.DeliveryDateSince != "" {
+		filter.add("date(o.delivery_date) >= date(?)", q.DeliveryDateSince)
+	}
+	if q.DeliveryDateBefore != "" {
+		filter.add("date(o.delivery_date) < date(?)", q.DeliveryDateBefore)
+	}
+
+	page := &OrderPage{Orders: []models.Order{}}
+	countSQL := "SELECT COUNT(*) FROM orders o " + filter.clause()
+	if err := m.db.QueryRowContext(ctx, countSQL, filter.args...).Scan(&page.Total); err != nil {
+		return nil, fmt.Errorf("counting orders: %w", err)
+	}
+
+	if q.StartingAfter != "" {
+		if err := m.checkCursor(ctx, "orders", q.StartingAfter); err != nil {
+			return nil, err
+		}
+		op := ">"
+		if desc {
+			op = "<"
+		}
+		cursorColumn := strings.TrimPrefix(sortColumn, "o.")
+		filter.add(fmt.Sprintf("(%s, o.id) %s (SELECT %s, id FROM orders WHERE id = ?)", sortColumn, op, cursorColumn), q.StartingAfter)
+	}
+
+	direction := "ASC"
+	if desc {
+		direction = "DESC"
+	}
+	limit := pageSize(q.Limit)
+	args := append(filter.args, limit+1, q.Offset)
+
+	rows, err := m.db.QueryContext(ctx, fmt.Sprintf(`
+        SELECT o.id, o.number, o.created, o.status, o.customer_id, o.company_name,
+               COALESCE(o.phone, ''), o.email_addresses, o.created_by, date(o.delivery_date),
+               COALESCE(o.reference, ''), COALESCE(o.internal_note, ''),
+               COALESCE(o.customer_po_number, ''), COALESCE(o.customer_note, ''),
+               o.standing_order_id, COALESCE(o.shipping_type, ''), o.shipping_address_id,
+               o.billing_address_id, o.currency, o.net_total, o.gross_total
+        FROM orders o
+        %s
+        ORDER BY %s %s, o.id %s
+        LIMIT ? OFFSET ?
+    `, filter.clause(), sortColumn, direction, direction), args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying orders: %w", err)
+	}
+	defer rows.Close()
+
+	type addressRefs struct{ shipping, billing sql.NullString }
+	var refs []addressRefs
+	for rows.Next() {
+		var order models.Order
+		var emails string
+		var ref addressRefs
+		err := rows.Scan(&order.ID, &order.Number, &order.Created, &order.Status, &order.CustomerID,
+			&order.CompanyName, &order.Phone, &emails, &order.CreatedBy, &order.DeliveryDate,
+			&order.Reference, &order.InternalNote, &order.CustomerPONumber, &order.CustomerNote,
+			&order.StandingOrderID, &order.ShippingType, &ref.shipping, &ref.billing,
+			&order.Currency, &order.NetTotal, &order.GrossTotal)
+		if err != nil {
+			return nil, fmt.Errorf("scanning order: %w", err)
+		}
+		if err := json.Unmarshal([]byte(emails), &order.EmailAddresses); err != nil {
+			return nil, fmt.Errorf("decoding email addresses for %s: %w", order.ID, err)
+		}
+		page.Orders = append(page.Orders, order)
+		refs = append(refs, ref)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(page.Orders) > limit {
+		page.Orders = page.Orders[:limit]
+		page.HasMore = true
+	}
+
+	for i := range page.Orders {
+		order := &page.Orders[i]
+		if order.ShippingAddress, err = m.address(ctx, refs[i].shipping); err != nil {
+			return nil, err
+		}
+		if order.BillingAddress, err = m.address(ctx, refs[i].billing); err != nil {
+			return nil, err
+		}
+		if order.OrderLines, err = m.orderLines(ctx, order.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return page, nil
+}
+
+func (m *Mirror) address(ctx context.Context, id sql.NullString) (models.Address, error) {
+	if !id.Valid {
+		return models.Address{}, nil
+	}
+	row := m.db.QueryRowContext(ctx, `SELECT `+addressColumns+` FROM addresses WHERE id = ?`, id.String)
+	address, err := scanAddress(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Address{}, nil
+	}
+	return address, err
+}
+
+func (m *Mirror) orderLines(ctx context.Context, orderID string) ([]models.OrderLine, error) {
+	rows, err := m.db.QueryContext(ctx, `
+        SELECT id, sku, name, COALESCE(options, ''), COALESCE(grouping_category_id, ''),
+               COALESCE(grouping_category_name, ''), shipping, quantity, unit_price, sub_total,
+               tax_rate_id, tax_name, tax_rate, tax_amount, COALESCE(preorder_window_id, ''),
+               on_hold, invoiced, paid, dispatched
+        FROM order_lines
+        WHERE order_id = ?
+        ORDER BY rowid
+    `, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("querying lines for order %s: %w", orderID, err)
+	}
+	defer rows.Close()
+
+	lines := []models.OrderLine{}
+	for rows.Next() {
+		var l models.OrderLine
+		err := rows.Scan(&l.ID, &l.SKU, &l.Name, &l.Options, &l.GroupingCategory.ID,
+			&l.GroupingCategory.Name, &l.Shipping, &l.Quantity, &l.UnitPrice, &l.SubTotal,
+			&l.TaxRateID, &l.TaxName, &l.TaxRate, &l.TaxAmount, &l.PreorderWindowID,
+			&l.OnHold, &l.Invoiced, &l.Paid, &l.Dispatched)
+		if err != nil {
+			return nil, fmt.Errorf("scanning order line: %w", err)
+		}
+		lines = append(lines, l)
+	}
+	return lines, rows.Err()
+}
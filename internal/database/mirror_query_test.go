@@ -0,0 +1,55 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DukeRupert/rr/internal/models"
+)
+
+func TestListCustomersSearch(t *testing.T) {
+	ctx := context.Background()
+	db := migratedTestDB(t)
+	mirror := NewMirror(db)
+	for _, c := range []models.Customer{
+		{ID: "c1", CompanyName: "100% Arabica", Status: "active"},
+		{ID: "c2", CompanyName: "Cafe One", Status: "active", Reference: "CAFE_1"},
+		{ID: "c3", CompanyName: "Cafe Two", Status: "active", Reference: "CAFE-2"},
+		{ID: "c4", CompanyName: `Back\Room`, Status: "active"},
+	} {
+		if err := mirror.UpsertCustomer(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		search string
+		want   []string
+	}{
+		{"cafe", []string{"c2", "c3"}},
+		{"%", []string{"c1"}},
+		{"_", []string{"c2"}},
+		{"e_1", []string{"c2"}},
+		{`\`, []string{"c4"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.search, func(t *testing.T) {
+			page, err := mirror.ListCustomers(ctx, CustomerQuery{Search: tt.search})
+			if err != nil {
+				t.Fatalf("ListCustomers() error = %v", err)
+			}
+			var got []string
+			for _, c := range page.Customers {
+				got = append(got, c.ID)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ListCustomers(%q) = %v, want %v", tt.search, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("ListCustomers(%q) = %v, want %v", tt.search, got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/DukeRupert/rr/internal/models"
+)
+
+// NotificationPreferences reads and writes customer_notifications and the
+// per-category notification_preferences. Customers without rows are
+// treated as opted in.
+type NotificationPreferences struct {
+	db *sql.DB
+}
+
+func NewNotificationPreferences(db *sql.DB) *NotificationPreferences {
+	return &NotificationPreferences{db: db}
+}
+
+// normalizeAddress makes address matching case-insensitive
+func normalizeAddress(address string) string {
+	return strings.ToLower(strings.TrimSpace(address))
+}
+
+// Get returns the customer's stored preferences, or the opted-in default
+// when nothing has been stored yet
+func (p *NotificationPreferences) Get(ctx context.Context, customerID string) (*models.CustomerNotification, error) {
+	var n models.CustomerNotification
+	err := p.db.QueryRowContext(ctx, `
+        SELECT id, customer_id, email_notify_days, created_at, updated_at
+        FROM customer_notifications
+        WHERE customer_id = ?
+    `, customerID).Scan(&n.ID, &n.CustomerID, &n.EmailNotifyDays, &n.CreatedAt, &n.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		n = models.CustomerNotification{CustomerID: customerID, EmailNotifyDays: true}
+	} else if err != nil {
+		return nil, fmt.Errorf("loading notification preferences for %s: %w", customerID, err)
+	}
+
+	n.Preferences, err = p.categories(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+func (p *NotificationPreferences) categories(ctx context.Context, customerID string) ([]models.NotificationPreference, error) {
+	rows, err := p.db.QueryContext(ctx, `
+        SELECT category, email_address, enabled
+        FROM notification_preferences
+        WHERE customer_id = ?
+        ORDER BY category, email_address
+    `, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("loading category preferences for %s: %w", customerID, err)
+	}
+	defer rows.Close()
+
+	prefs := []models.NotificationPreference{}
+	for rows.Next() {
+		var pref models.NotificationPreference
+		if err := rows.Scan(&pref.Category, &pref.EmailAddress, &pref.Enabled); err != nil {
+			return nil, fmt.Errorf("scanning category preference: %w", err)
+		}
+		prefs = append(prefs, pref)
+	}
+	return prefs, rows.Err()
+}
+
+// Allowed reports whether the customer wants emails of category sent to
+// address. The customer-wide switch wins, then an address-specific row,
+// then a row for all addresses; with no rows the answer is yes.
+func (p *NotificationPreferences) Allowed(ctx context.Context, customerID string, category models.NotificationCategory, address string) (bool, error) {
+	var enabled bool
+	err := p.db.QueryRowContext(ctx, `
+        SELECT COALESCE(
+            (SELECT email_notify_days FROM customer_notifications WHERE customer_id = ?),
+            true
+        )
+    `, customerID).Scan(&enabled)
+	if err != nil {
+		return false, fmt.Errorf("loading notification preferences for %s: %w", customerID, err)
+	}
+	if !enabled {
+		return false, nil
+	}
+
+	err = p.db.QueryRowContext(ctx, `
+        SELECT enabled
+        FROM notification_preferences
+        WHERE customer_id = ? AND category = ? AND email_address IN (?, '')
+        ORDER BY email_address = ''
+        LIMIT 1
+    `, customerID, category, normalizeAddress(address)).Scan(&enabled)
+	if errors.Is(err, sql.ErrNoRows) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("loading %s preference for %s: %w", category, customerID, err)
+	}
+	return enabled, nil
+}
+
+// SetCategory stores a per-category preference for the customer
+func (p *NotificationPreferences) SetCategory(ctx context.Context, customerID string, pref models.NotificationPreference) error {
+	_, err := p.Update(ctx, customerID, nil, []models.NotificationPreference{pref})
+	return err
+}
+
+// Update sets the customer-wide switch, unless emailEnabled is nil, and
+// stores each per-category preference. Everything is saved in one
+// transaction, so an invalid category leaves the stored preferences as
+// they were.
+func (p *NotificationPreferences) Update(ctx context.Context, customerID string, emailEnabled *bool, prefs []models.NotificationPreference) (*models.CustomerNotification, error) {
+	for _, pref := range prefs {
+		if !pref.Category.Validate() {
+			return nil, fmt.Errorf("invalid notification category %q", pref.Category)
+		}
+	}
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if emailEnabled != nil {
+		if err := setEmailEnabled(ctx, tx, customerID, *emailEnabled); err != nil {
+			return nil, err
+		}
+	}
+	for _, pref := range prefs {
+		if err := setCategory(ctx, tx, customerID, pref); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("saving notification preferences for %s: %w", customerID, err)
+	}
+	return p.Get(ctx, customerID)
+}
+
+func setEmailEnabled(ctx context.Context, tx *sql.Tx, customerID string, enabled bool) error {
+	_, err := tx.ExecContext(ctx, `
+        INSERT INTO customer_notifications (customer_id, email_notify_days)
+        VALUES (?, ?)
+        ON CONFLICT(customer_id) DO UPDATE SET
+            email_notify_days = excluded.email_notify_days,
+            updated_at = CURRENT_TIMESTAMP
+    `, customerID, enabled)
+	if err != nil {
+		return fmt.Errorf("saving notification preferences for %s: %w", customerID, err)
+	}
+	return nil
+}
+
+func setCategory(ctx context.Context, tx *sql.Tx, customerID string, pref models.NotificationPreference) error {
+	_, err := tx.ExecContext(ctx, `
+        INSERT INTO notification_preferences (customer_id, category, email_address, enabled)
+        VALUES (?, ?, ?, ?)
+        ON CONFLICT(customer_id, category, email_address) DO UPDATE SET
+            enabled = excluded.enabled,
+            updated_at = CURRENT_TIMESTAMP
+    `, customerID, pref.Category, normalizeAddress(pref.EmailAddress), pref.Enabled)
+	if err != nil {
+		return fmt.Errorf("saving %s preference for %s: %w", pref.Category, customerID, err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DukeRupert/rr/internal/models"
+)
+
+func TestNotificationPreferencesUpdate(t *testing.T) {
+	off := false
+	tests := []struct {
+		name         string
+		emailEnabled *bool
+		prefs        []models.NotificationPreference
+		wantErr      bool
+		wantEnabled  bool
+		wantPrefs    int
+	}{
+		{
+			name:         "switch and categories saved together",
+			emailEnabled: &off,
+			prefs: []models.NotificationPreference{
+				{Category: models.NotificationOrderReminders, Enabled: false},
+				{Category: models.NotificationInvoices, EmailAddress: "Accounts@Example.com", Enabled: true},
+			},
+			wantEnabled: false,
+			wantPrefs:   2,
+		},
+		{
+			name:         "invalid category saves nothing",
+			emailEnabled: &off,
+			prefs: []models.NotificationPreference{
+				{Category: models.NotificationOrderReminders, Enabled: false},
+				{Category: "newsletters", Enabled: false},
+			},
+			wantErr:     true,
+			wantEnabled: true,
+			wantPrefs:   0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			db := migratedTestDB(t)
+			if err := NewMirror(db).UpsertCustomer(ctx, models.Customer{ID: "c1", CompanyName: "Cafe One", Status: "active"}); err != nil {
+				t.Fatal(err)
+			}
+			prefs := NewNotificationPreferences(db)
+
+			_, err := prefs.Update(ctx, "c1", tt.emailEnabled, tt.prefs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Update() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			got, err := prefs.Get(ctx, "c1")
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if got.EmailNotifyDays != tt.wantEnabled || len(got.Preferences) != tt.wantPrefs {
+				t.Errorf("Get() = enabled %v with %d preferences, want %v with %d",
+					got.EmailNotifyDays, len(got.Preferences), tt.wantEnabled, tt.wantPrefs)
+			}
+		})
+	}
+}
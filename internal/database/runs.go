@@ -0,0 +1,157 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/DukeRupert/rr/internal/models"
+)
+
+// EmailRuns records bulk sends and the outcome for each recipient
+type EmailRuns struct {
+	db *sql.DB
+}
+
+func NewEmailRuns(db *sql.DB) *EmailRuns {
+	return &EmailRuns{db: db}
+}
+
+// RunPage is one page of run history, newest first
+type RunPage struct {
+	Runs  []models.EmailRun `json:"runs"`
+	Total int               `json:"total"`
+}
+
+const runColumns = `id, kind, trigger, schedule_id, campaign, delivery_week, category, subject,
+    status, error, sent, failed, skipped, started_at, finished_at`
+
+func scanRun(row rowScanner) (models.EmailRun, error) {
+	var r models.EmailRun
+	var scheduleID sql.NullInt64
+	var finishedAt sql.NullTime
+	err := row.Scan(&r.ID, &r.Kind, &r.Trigger, &scheduleID, &r.Campaign, &r.DeliveryWeek, &r.Category, &r.Subject,
+		&r.Status, &r.Error, &r.Sent, &r.Failed, &r.Skipped, &r.StartedAt, &finishedAt)
+	if err != nil {
+		return models.EmailRun{}, err
+	}
+	if scheduleID.Valid {
+		r.ScheduleID = &scheduleID.Int64
+	}
+	if finishedAt.Valid {
+		r.FinishedAt = &finishedAt.Time
+	}
+	return r, nil
+}
+
+// Start records a new run as running and returns its ID
+func (r *EmailRuns) Start(ctx context.Context, run models.EmailRun) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `
+        INSERT INTO reminder_runs (
+            kind, trigger, schedule_id, campaign, delivery_week, category, subject, status, started_at
+        ) VALUES (?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+    `, run.Kind, run.Trigger, run.ScheduleID, run.Campaign, run.DeliveryWeek,
+		run.Category, run.Subject, models.RunStatusRunning)
+	if err != nil {
+		return 0, fmt.Errorf("recording run: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+// RecordEmail stores the outcome for one recipient
+func (r *EmailRuns) RecordEmail(ctx context.Context, e models.SentEmail) error {
+	_, err := r.db.ExecContext(ctx, `
+        INSERT INTO sent_emails (run_id, customer_id, company_name, email_address, message_id, status, error)
+        VALUES (?, ?, ?, ?, ?, ?, ?)
+    `, e.RunID, e.CustomerID, e.CompanyName, e.EmailAddress, e.MessageID, e.Status, e.Error)
+	if err != nil {
+		return fmt.Errorf("recording email for %s: %w", e.CustomerID, err)
+	}
+	return nil
+}
+
+// Finish marks the run completed, or failed when runErr is set, and stores
+// its final counts
+func (r *EmailRuns) Finish(ctx context.Context, id int64, sent, failed, skipped int, runErr error) error {
+	status, message := models.RunStatusCompleted, ""
+	if runErr != nil {
+		status, message = models.RunStatusFailed, runErr.Error()
+	}
+
+	_, err := r.db.ExecContext(ctx, `
+        UPDATE reminder_runs SET
+            status = ?, error = ?, sent = ?, failed = ?, skipped = ?,
+            finished_at = CURRENT_TIMESTAMP
+        WHERE id = ?
+    `, status, message, sent, failed, skipped, id)
+	if err != nil {
+		return fmt.Errorf("finishing run %d: %w", id, err)
+	}
+	return nil
+}
+
+// List returns runs newest first without their emails
+func (r *EmailRuns) List(ctx context.Context, limit, offset int) (*RunPage, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	page := &RunPage{Runs: []models.EmailRun{}}
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM reminder_runs`).Scan(&page.Total); err != nil {
+		return nil, fmt.Errorf("counting runs: %w", err)
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT `+runColumns+` FROM reminder_runs
+        ORDER BY started_at DESC, id DESC
+        LIMIT ? OFFSET ?
+    `, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("listing runs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		run, err := scanRun(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning run: %w", err)
+		}
+		page.Runs = append(page.Runs, run)
+	}
+	return page, rows.Err()
+}
+
+// Get returns a run with every recipient outcome, or sql.ErrNoRows if it
+// doesn't exist
+func (r *EmailRuns) Get(ctx context.Context, id int64) (*models.EmailRun, error) {
+	run, err := scanRun(r.db.QueryRowContext(ctx,
+		`SELECT `+runColumns+` FROM reminder_runs WHERE id = ?`, id))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT id, run_id, customer_id, company_name, email_address, message_id, status, error, created_at
+        FROM sent_emails
+        WHERE run_id = ?
+        ORDER BY id
+    `, id)
+	if err != nil {
+		return nil, fmt.Errorf("loading emails for run %d: %w", id, err)
+	}
+	defer rows.Close()
+
+	run.Emails = []models.SentEmail{}
+	for rows.Next() {
+		var e models.SentEmail
+		if err := rows.Scan(&e.ID, &e.RunID, &e.CustomerID, &e.CompanyName, &e.EmailAddress,
+			&e.MessageID, &e.Status, &e.Error, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning email: %w", err)
+		}
+		run.Emails = append(run.Emails, e)
+	}
+	return &run, rows.Err()
+}
@@ -0,0 +1,123 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/DukeRupert/rr/internal/models"
+)
+
+// ReminderSchedules stores when order reminders are sent
+type ReminderSchedules struct {
+	db *sql.DB
+}
+
+func NewReminderSchedules(db *sql.DB) *ReminderSchedules {
+	return &ReminderSchedules{db: db}
+}
+
+const scheduleColumns = `id, name, cron_expression, weekday, time_of_day, timezone, enabled, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSchedule(row rowScanner) (models.ReminderSchedule, error) {
+	var s models.ReminderSchedule
+	var weekday sql.NullInt64
+	err := row.Scan(&s.ID, &s.Name, &s.CronExpression, &weekday, &s.TimeOfDay,
+		&s.Timezone, &s.Enabled, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return models.ReminderSchedule{}, err
+	}
+	if weekday.Valid {
+		w := time.Weekday(weekday.Int64)
+		s.Weekday = &w
+	}
+	return s, nil
+}
+
+// List returns every schedule, enabled or not
+func (r *ReminderSchedules) List(ctx context.Context) ([]models.ReminderSchedule, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+scheduleColumns+` FROM reminder_schedules ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("listing reminder schedules: %w", err)
+	}
+	defer rows.Close()
+
+	schedules := []models.ReminderSchedule{}
+	for rows.Next() {
+		s, err := scanSchedule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning reminder schedule: %w", err)
+		}
+		schedules = append(schedules, s)
+	}
+	return schedules, rows.Err()
+}
+
+// Get returns one schedule, or sql.ErrNoRows if it doesn't exist
+func (r *ReminderSchedules) Get(ctx context.Context, id int64) (*models.ReminderSchedule, error) {
+	s, err := scanSchedule(r.db.QueryRowContext(ctx,
+		`SELECT `+scheduleColumns+` FROM reminder_schedules WHERE id = ?`, id))
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Create stores a new schedule and returns it as saved
+func (r *ReminderSchedules) Create(ctx context.Context, s models.ReminderSchedule) (*models.ReminderSchedule, error) {
+	res, err := r.db.ExecContext(ctx, `
+        INSERT INTO reminder_schedules (name, cron_expression, weekday, time_of_day, timezone, enabled)
+        VALUES (?, ?, ?, ?, ?, ?)
+    `, s.Name, s.CronExpression, weekdayValue(s.Weekday), s.TimeOfDay, s.Timezone, s.Enabled)
+	if err != nil {
+		return nil, fmt.Errorf("creating reminder schedule: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return r.Get(ctx, id)
+}
+
+// Update replaces a schedule's settings. It returns sql.ErrNoRows if the
+// schedule doesn't exist.
+func (r *ReminderSchedules) Update(ctx context.Context, s models.ReminderSchedule) (*models.ReminderSchedule, error) {
+	res, err := r.db.ExecContext(ctx, `
+        UPDATE reminder_schedules SET
+            name = ?, cron_expression = ?, weekday = ?, time_of_day = ?,
+            timezone = ?, enabled = ?, updated_at = CURRENT_TIMESTAMP
+        WHERE id = ?
+    `, s.Name, s.CronExpression, weekdayValue(s.Weekday), s.TimeOfDay, s.Timezone, s.Enabled, s.ID)
+	if err != nil {
+		return nil, fmt.Errorf("updating reminder schedule: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return r.Get(ctx, s.ID)
+}
+
+// Delete removes a schedule. It returns sql.ErrNoRows if the schedule
+// doesn't exist.
+func (r *ReminderSchedules) Delete(ctx context.Context, id int64) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM reminder_schedules WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("deleting reminder schedule: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func weekdayValue(w *time.Weekday) interface{} {
+	if w == nil {
+		return nil
+	}
+	return int(*w)
+}
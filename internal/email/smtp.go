@@ -31,6 +31,9 @@ func (c *SMTPClient) SendEmail(email Email) (*EmailResponse, error) {
 	headers = append(headers, fmt.Sprintf("To: %s", email.To))
 	headers = append(headers, fmt.Sprintf("Subject: %s", email.Subject))
 	headers = append(headers, "MIME-Version: 1.0")
+	for _, h := range email.Headers {
+		headers = append(headers, fmt.Sprintf("%s: %s", h.Name, h.Value))
+	}
 
 	var body string
 	if email.HtmlBody != "" {
@@ -27,6 +27,18 @@ type Customer struct {
 	OrderInterval *int `json:"order_interval,omitempty" db:"order_interval" form:"order_interval"`
 }
 
+// Order intervals are how many weeks apart a customer places orders
+const (
+	MinOrderInterval     = 1
+	MaxOrderInterval     = 4
+	DefaultOrderInterval = MinOrderInterval
+)
+
+// ValidOrderInterval reports whether weeks fits the order_interval column
+func ValidOrderInterval(weeks int) bool {
+	return weeks >= MinOrderInterval && weeks <= MaxOrderInterval
+}
+
 // Buyer represents a user that can log in and access the ordering site
 type Buyer struct {
 	Name         string `json:"name" db:"name" form:"buyer_name"`
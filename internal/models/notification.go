@@ -1,9 +1,58 @@
 package models
 
 type CustomerNotification struct {
-	ID              int64  `db:"id"`
-	CustomerID      string `db:"customer_id"`
-	EmailNotifyDays bool   `db:"email_notify_days"`
-	CreatedAt       string `db:"created_at"`
-	UpdatedAt       string `db:"updated_at"`
+	ID              int64  `json:"id,omitempty" db:"id"`
+	CustomerID      string `json:"customer_id" db:"customer_id"`
+	EmailNotifyDays bool   `json:"email_notify_days" db:"email_notify_days"`
+	CreatedAt       string `json:"created_at,omitempty" db:"created_at"`
+	UpdatedAt       string `json:"updated_at,omitempty" db:"updated_at"`
+
+	// Preferences refine EmailNotifyDays per category and recipient
+	Preferences []NotificationPreference `json:"preferences" db:"-"`
+}
+
+// NotificationPreference opts one category of email in or out, either for
+// a single recipient address or, when EmailAddress is empty, for all of them
+type NotificationPreference struct {
+	Category     NotificationCategory `json:"category" db:"category"`
+	EmailAddress string               `json:"email_address" db:"email_address"`
+	Enabled      bool                 `json:"enabled" db:"enabled"`
+}
+
+// NotificationCategory represents a kind of email we send customers
+type NotificationCategory string
+
+const (
+	NotificationOrderReminders  NotificationCategory = "order_reminders"
+	NotificationAnnouncements   NotificationCategory = "announcements"
+	NotificationDispatchNotices NotificationCategory = "dispatch_notices"
+	NotificationInvoices        NotificationCategory = "invoices"
+)
+
+// Validate checks if a notification category is valid
+func (c NotificationCategory) Validate() bool {
+	switch c {
+	case NotificationOrderReminders, NotificationAnnouncements,
+		NotificationDispatchNotices, NotificationInvoices:
+		return true
+	default:
+		return false
+	}
+}
+
+// For returns the address that should receive emails of the given
+// category, falling back to the orders address when the specific one
+// isn't set
+func (e EmailAddresses) For(category NotificationCategory) string {
+	switch category {
+	case NotificationDispatchNotices:
+		if e.Dispatches != "" {
+			return e.Dispatches
+		}
+	case NotificationInvoices:
+		if e.Invoices != "" {
+			return e.Invoices
+		}
+	}
+	return e.Orders
 }
@@ -0,0 +1,71 @@
+package models
+
+import "time"
+
+// RunKind is what a bulk send was sending
+type RunKind string
+
+const (
+	RunKindOrderReminder RunKind = "order_reminder"
+	RunKindAdHoc         RunKind = "adhoc"
+)
+
+// RunTrigger is what started a bulk send
+type RunTrigger string
+
+const (
+	RunTriggerScheduled RunTrigger = "scheduled"
+	RunTriggerManual    RunTrigger = "manual"
+)
+
+// Run statuses
+const (
+	RunStatusRunning   = "running"
+	RunStatusCompleted = "completed"
+	RunStatusFailed    = "failed"
+)
+
+// EmailRun records one reminder or ad-hoc send to many customers
+type EmailRun struct {
+	ID         int64      `json:"id" db:"id"`
+	Kind       RunKind    `json:"kind" db:"kind"`
+	Trigger    RunTrigger `json:"trigger" db:"trigger"`
+	ScheduleID *int64     `json:"schedule_id,omitempty" db:"schedule_id"`
+	// Campaign and DeliveryWeek key reminder runs so a week's reminder is
+	// only sent once per customer; both are empty for ad-hoc sends
+	Campaign     string               `json:"campaign,omitempty" db:"campaign"`
+	DeliveryWeek string               `json:"delivery_week,omitempty" db:"delivery_week"`
+	Category     NotificationCategory `json:"category" db:"category"`
+	Subject      string               `json:"subject" db:"subject"`
+	Status       string               `json:"status" db:"status"`
+	Error        string               `json:"error,omitempty" db:"error"`
+	Sent         int                  `json:"sent" db:"sent"`
+	Failed       int                  `json:"failed" db:"failed"`
+	Skipped      int                  `json:"skipped" db:"skipped"`
+	StartedAt    time.Time            `json:"started_at" db:"started_at"`
+	FinishedAt   *time.Time           `json:"finished_at,omitempty" db:"finished_at"`
+
+	// Emails is only loaded when fetching a single run
+	Emails []SentEmail `json:"emails,omitempty" db:"-"`
+}
+
+// Sent email statuses
+const (
+	SentEmailSent    = "sent"
+	SentEmailFailed  = "failed"
+	SentEmailSkipped = "skipped"
+)
+
+// SentEmail is the outcome for one customer in a run. Error holds the
+// failure or skip reason.
+type SentEmail struct {
+	ID           int64     `json:"id" db:"id"`
+	RunID        int64     `json:"run_id" db:"run_id"`
+	CustomerID   string    `json:"customer_id" db:"customer_id"`
+	CompanyName  string    `json:"company_name" db:"company_name"`
+	EmailAddress string    `json:"email_address" db:"email_address"`
+	MessageID    string    `json:"message_id,omitempty" db:"message_id"`
+	Status       string    `json:"status" db:"status"`
+	Error        string    `json:"error,omitempty" db:"error"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
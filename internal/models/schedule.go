@@ -0,0 +1,53 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// ReminderSchedule says when order reminders are sent. Either
+// CronExpression is set, or Weekday and TimeOfDay are.
+type ReminderSchedule struct {
+	ID             int64  `json:"id" db:"id"`
+	Name           string `json:"name" db:"name"`
+	CronExpression string `json:"cron_expression,omitempty" db:"cron_expression"`
+	// Weekday is 0 (Sunday) through 6 (Saturday)
+	Weekday *time.Weekday `json:"weekday,omitempty" db:"weekday"`
+	// TimeOfDay is a 24-hour "15:04" time
+	TimeOfDay string    `json:"time_of_day,omitempty" db:"time_of_day"`
+	Timezone  string    `json:"timezone" db:"timezone"`
+	Enabled   bool      `json:"enabled" db:"enabled"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+
+	// NextRun is when the scheduler will next fire, if it is enabled
+	NextRun *time.Time `json:"next_run,omitempty" db:"-"`
+}
+
+// Validate checks the fields that don't need a cron parser
+func (s ReminderSchedule) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if _, err := time.LoadLocation(s.Timezone); err != nil || s.Timezone == "" {
+		return fmt.Errorf("invalid timezone: %q", s.Timezone)
+	}
+
+	if s.CronExpression != "" {
+		if s.Weekday != nil || s.TimeOfDay != "" {
+			return fmt.Errorf("use either cron_expression or weekday and time_of_day, not both")
+		}
+		return nil
+	}
+
+	if s.Weekday == nil || s.TimeOfDay == "" {
+		return fmt.Errorf("cron_expression or weekday and time_of_day is required")
+	}
+	if *s.Weekday < time.Sunday || *s.Weekday > time.Saturday {
+		return fmt.Errorf("weekday must be between 0 (Sunday) and 6 (Saturday)")
+	}
+	if _, err := time.Parse("15:04", s.TimeOfDay); err != nil {
+		return fmt.Errorf("time_of_day must be HH:MM")
+	}
+	return nil
+}
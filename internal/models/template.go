@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// EmailTemplate is a stored email template. Content templates fill the
+// shared layout; the layout's Subject is unused.
+type EmailTemplate struct {
+	Name        string    `json:"name" db:"name"`
+	Description string    `json:"description" db:"description"`
+	Subject     string    `json:"subject" db:"subject"`
+	HtmlBody    string    `json:"html_body" db:"html_body"`
+	TextBody    string    `json:"text_body" db:"text_body"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
@@ -2,15 +2,24 @@ package orderspace
 
 import (
 	"bytes"
-	"database/sql"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
+)
 
-	_ "github.com/mattn/go-sqlite3"
+const (
+	// defaultTokenLifetime is assumed when the auth response omits expires_in
+	defaultTokenLifetime = 30 * time.Minute
+	// tokenExpirySkew treats tokens as expired slightly early so a request
+	// doesn't race the real expiry in flight. Short-lived tokens give up at
+	// most a quarter of their lifetime to it.
+	tokenExpirySkew = time.Minute
 )
 
 // AuthResponse represents the OAuth token response
@@ -25,6 +34,16 @@ type AuthResponse struct {
 type TokenInfo struct {
 	Token     string
 	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// Valid reports whether the token can still be used at the given time
+func (t TokenInfo) Valid(now time.Time) bool {
+	skew := tokenExpirySkew
+	if lifetime := t.ExpiresAt.Sub(t.CreatedAt); lifetime < 4*skew {
+		skew = max(lifetime/4, 0)
+	}
+	return t.Token != "" && now.Before(t.ExpiresAt.Add(-skew))
 }
 
 // Client represents the API client with auth capabilities
@@ -33,70 +52,74 @@ type Client struct {
 	ClientID     string
 	ClientSecret string
 	HTTPClient   *http.Client
-	DB           *sql.DB
+	Tokens       TokenStore
+	Retry        RetryPolicy
+
+	// refreshMu serialises token refreshes so concurrent callers that find
+	// an expired or rejected token share a single new one
+	refreshMu sync.Mutex
 }
 
-// NewClient creates a new API client with database connection
-func NewClient(id, secret string, db *sql.DB) (*Client, error) {
-	client := &Client{
+// NewClient creates a new API client that persists tokens in store
+func NewClient(id, secret string, store TokenStore) (*Client, error) {
+	if store == nil {
+		return nil, fmt.Errorf("token store is required")
+	}
+
+	return &Client{
 		BaseURL:      "https://api.orderspace.com/v1",
 		ClientID:     id,
 		ClientSecret: secret,
 		HTTPClient:   &http.Client{Timeout: time.Second * 30},
-		DB:           db,
+		Tokens:       store,
+		Retry:        DefaultRetryPolicy(),
+	}, nil
+}
+
+// GetValidToken returns a valid token or obtains a new one if necessary
+func (c *Client) GetValidToken() (string, error) {
+	return c.GetValidTokenContext(context.Background())
+}
+
+// GetValidTokenContext is GetValidToken bound to ctx
+func (c *Client) GetValidTokenContext(ctx context.Context) (string, error) {
+	if token, err := c.Tokens.Latest(ctx); err == nil && token.Valid(time.Now()) {
+		return token.Token, nil
 	}
 
-	// Initialize the tokens table
-	err := client.initDB()
-	if err != nil {
-		return nil, fmt.Errorf("failed to initialize database: %v", err)
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	// Another caller may have refreshed while we waited for the lock
+	if token, err := c.Tokens.Latest(ctx); err == nil && token.Valid(time.Now()) {
+		return token.Token, nil
 	}
 
-	return client, nil
+	// Get new token if none exists or current one is expired
+	return c.refreshToken(ctx)
 }
 
-// initDB creates the tokens table if it doesn't exist
-func (c *Client) initDB() error {
-	_, err := c.DB.Exec(`
-        CREATE TABLE IF NOT EXISTS tokens (
-            id INTEGER PRIMARY KEY,
-            access_token TEXT NOT NULL,
-            created_at DATETIME NOT NULL
-        )
-    `)
-	return err
-}
+// renewToken replaces a token the API rejected. Callers that were rejected
+// with the same token while a refresh was in flight reuse its result.
+func (c *Client) renewToken(ctx context.Context, rejected string) (string, error) {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
 
-// GetValidToken returns a valid token or obtains a new one if necessary
-func (c *Client) GetValidToken() (string, error) {
-	// Try to get existing valid token
-	var token TokenInfo
-	err := c.DB.QueryRow(`
-        SELECT access_token, created_at 
-        FROM tokens 
-        ORDER BY created_at DESC 
-        LIMIT 1
-    `).Scan(&token.Token, &token.CreatedAt)
-
-	if err == nil {
-		// Check if token is still valid (less than 25 minutes old to add buffer)
-		if time.Since(token.CreatedAt) < 25*time.Minute {
-			return token.Token, nil
-		}
+	if token, err := c.Tokens.Latest(ctx); err == nil && token.Token != rejected && token.Valid(time.Now()) {
+		return token.Token, nil
 	}
 
-	// Get new token if none exists or current one is expired
-	return c.refreshToken()
+	return c.refreshToken(ctx)
 }
 
 // refreshToken obtains a new access token from the auth endpoint
-func (c *Client) refreshToken() (string, error) {
+func (c *Client) refreshToken(ctx context.Context) (string, error) {
 	data := url.Values{}
 	data.Set("client_id", c.ClientID)
 	data.Set("client_secret", c.ClientSecret)
 	data.Set("grant_type", "client_credentials")
 
-	req, err := http.NewRequest("POST",
+	req, err := http.NewRequestWithContext(ctx, "POST",
 		"https://identity.orderspace.com/oauth/token",
 		strings.NewReader(data.Encode()))
 	if err != nil {
@@ -120,11 +143,17 @@ func (c *Client) refreshToken() (string, error) {
 		return "", fmt.Errorf("failed to decode response: %v", err)
 	}
 
-	// Store new token in database
-	_, err = c.DB.Exec(`
-        INSERT INTO tokens (access_token, created_at) 
-        VALUES (?, ?)
-    `, authResp.AccessToken, time.Now())
+	now := time.Now()
+	lifetime := time.Duration(authResp.ExpiresIn) * time.Second
+	if lifetime <= 0 {
+		lifetime = defaultTokenLifetime
+	}
+
+	err = c.Tokens.Save(ctx, TokenInfo{
+		Token:     authResp.AccessToken,
+		CreatedAt: now,
+		ExpiresAt: now.Add(lifetime),
+	})
 	if err != nil {
 		return "", fmt.Errorf("failed to store token: %v", err)
 	}
@@ -132,21 +161,56 @@ func (c *Client) refreshToken() (string, error) {
 	return authResp.AccessToken, nil
 }
 
-// MakeAuthenticatedRequest makes a request with the current valid token
+// MakeAuthenticatedRequest makes a request with the current valid token.
+// Idempotent requests are retried according to the client's RetryPolicy,
+// and a 401 triggers one token refresh and replay.
 func (c *Client) MakeAuthenticatedRequest(method, path string, body []byte) (*http.Response, error) {
-	token, err := c.GetValidToken()
+	return c.MakeAuthenticatedRequestContext(context.Background(), method, path, body)
+}
+
+// MakeAuthenticatedRequestContext is MakeAuthenticatedRequest bound to ctx
+func (c *Client) MakeAuthenticatedRequestContext(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	token, err := c.GetValidTokenContext(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get valid token: %v", err)
 	}
 
-	url := fmt.Sprintf("%s%s", c.BaseURL, path)
-	req, err := http.NewRequest(method, url, bytes.NewBuffer(body))
+	resp, err := c.sendAuthenticated(ctx, token, method, path, body)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	// The token was revoked or expired early; a 401 means the request was
+	// never processed, so it is safe to replay once with a fresh token
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	token, err = c.renewToken(ctx, token)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
+		return nil, fmt.Errorf("failed to refresh token: %v", err)
 	}
 
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
-	req.Header.Add("Content-Type", "application/json")
+	return c.sendAuthenticated(ctx, token, method, path, body)
+}
+
+// sendAuthenticated sends a single request with token, retrying transient
+// failures when the method is idempotent
+func (c *Client) sendAuthenticated(ctx context.Context, token, method, path string, body []byte) (*http.Response, error) {
+	url := fmt.Sprintf("%s%s", c.BaseURL, path)
+	send := func() (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer(body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %v", err)
+		}
 
-	return c.HTTPClient.Do(req)
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+		req.Header.Add("Content-Type", "application/json")
+
+		return c.HTTPClient.Do(req)
+	}
+
+	if !isIdempotent(method) {
+		return send()
+	}
+	return c.Retry.doWithRetry(ctx, send)
 }
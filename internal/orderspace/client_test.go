@@ -0,0 +1,38 @@
+package orderspace
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenInfoValid(t *testing.T) {
+	created := time.Date(2026, 10, 5, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		lifetime time.Duration
+		age      time.Duration
+		want     bool
+	}{
+		{"fresh", 30 * time.Minute, 0, true},
+		{"inside the skew", 30 * time.Minute, 29*time.Minute + 30*time.Second, false},
+		{"expired", 30 * time.Minute, 31 * time.Minute, false},
+		{"short-lived token is usable", time.Minute, 30 * time.Second, true},
+		{"short-lived token in its last quarter", time.Minute, 50 * time.Second, false},
+		{"very short-lived token", 4 * time.Second, 2 * time.Second, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := TokenInfo{Token: "token", CreatedAt: created, ExpiresAt: created.Add(tt.lifetime)}
+			if got := token.Valid(created.Add(tt.age)); got != tt.want {
+				t.Errorf("Valid() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("empty token", func(t *testing.T) {
+		if (TokenInfo{CreatedAt: created, ExpiresAt: created.Add(time.Hour)}).Valid(created) {
+			t.Error("Valid() = true for an empty token")
+		}
+	})
+}
@@ -1,9 +1,12 @@
 package orderspace
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/mail"
 	"net/url"
 	"time"
 
@@ -24,6 +27,11 @@ type CustomerResponse struct {
 }
 
 func (c *Client) ListCustomers(params *CustomerListParams) (*CustomerResponse, error) {
+	return c.ListCustomersContext(context.Background(), params)
+}
+
+// ListCustomersContext is ListCustomers bound to ctx
+func (c *Client) ListCustomersContext(ctx context.Context, params *CustomerListParams) (*CustomerResponse, error) {
 	// Build query parameters
 	baseURL := "/customers"
 	if params != nil {
@@ -53,14 +61,14 @@ func (c *Client) ListCustomers(params *CustomerListParams) (*CustomerResponse, e
 	}
 
 	// Make authenticated request
-	resp, err := c.MakeAuthenticatedRequest("GET", baseURL, nil)
+	resp, err := c.MakeAuthenticatedRequestContext(ctx, "GET", baseURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("error making request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, newAPIError(resp)
 	}
 
 	var result CustomerResponse
@@ -70,3 +78,244 @@ func (c *Client) ListCustomers(params *CustomerListParams) (*CustomerResponse, e
 
 	return &result, nil
 }
+
+// GetCustomer retrieves a single customer by ID
+func (c *Client) GetCustomer(id string) (*models.Customer, error) {
+	return c.GetCustomerContext(context.Background(), id)
+}
+
+// GetCustomerContext is GetCustomer bound to ctx
+func (c *Client) GetCustomerContext(ctx context.Context, id string) (*models.Customer, error) {
+	resp, err := c.MakeAuthenticatedRequestContext(ctx, "GET", "/customers/"+url.PathEscape(id), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp)
+	}
+
+	var result struct {
+		Customer models.Customer `json:"customer"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return &result.Customer, nil
+}
+
+// EachCustomer calls fn for every customer matching params, following
+// starting_after until Orderspace reports there are no more pages
+func (c *Client) EachCustomer(params *CustomerListParams, fn func(models.Customer) error) error {
+	return c.EachCustomerContext(context.Background(), params, fn)
+}
+
+// EachCustomerContext is EachCustomer bound to ctx
+func (c *Client) EachCustomerContext(ctx context.Context, params *CustomerListParams, fn func(models.Customer) error) error {
+	var page CustomerListParams
+	if params != nil {
+		page = *params
+	}
+
+	for {
+		resp, err := c.ListCustomersContext(ctx, &page)
+		if err != nil {
+			return err
+		}
+
+		for _, customer := range resp.Customers {
+			if err := fn(customer); err != nil {
+				if stopped(err) {
+					return nil
+				}
+				return err
+			}
+		}
+
+		if !resp.HasMore || len(resp.Customers) == 0 {
+			return nil
+		}
+		page.StartingAfter = resp.Customers[len(resp.Customers)-1].ID
+	}
+}
+
+// AllCustomers collects every customer matching params. A positive
+// maxResults caps how many are returned; zero means no cap.
+func (c *Client) AllCustomers(params *CustomerListParams, maxResults int) ([]models.Customer, error) {
+	return c.AllCustomersContext(context.Background(), params, maxResults)
+}
+
+// AllCustomersContext is AllCustomers bound to ctx
+func (c *Client) AllCustomersContext(ctx context.Context, params *CustomerListParams, maxResults int) ([]models.Customer, error) {
+	var customers []models.Customer
+	err := c.EachCustomerContext(ctx, params, func(customer models.Customer) error {
+		customers = append(customers, customer)
+		if maxResults > 0 && len(customers) >= maxResults {
+			return ErrStopIteration
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return customers, nil
+}
+
+// CustomerRequest represents the structure of a customer create or update request
+type CustomerRequest struct {
+	Customer CustomerRequestBody `json:"customer"`
+}
+
+// CustomerRequestBody holds the customer fields to set. Empty fields are
+// omitted, so an update only changes what the caller supplies.
+type CustomerRequestBody struct {
+	CompanyName     string                 `json:"company_name,omitempty"`
+	Status          string                 `json:"status,omitempty"`
+	Reference       string                 `json:"reference,omitempty"`
+	InternalNote    string                 `json:"internal_note,omitempty"`
+	Phone           string                 `json:"phone,omitempty"`
+	Buyers          []models.Buyer         `json:"buyers,omitempty"`
+	EmailAddresses  *EmailAddressesRequest `json:"email_addresses,omitempty"`
+	TaxNumber       string                 `json:"tax_number,omitempty"`
+	TaxRateID       *string                `json:"tax_rate_id,omitempty"`
+	Addresses       []models.Address       `json:"addresses,omitempty"`
+	MinimumSpend    *float64               `json:"minimum_spend,omitempty"`
+	PaymentTermsID  *string                `json:"payment_terms_id,omitempty"`
+	CustomerGroupID *string                `json:"customer_group_id,omitempty"`
+	PriceListID     *string                `json:"price_list_id,omitempty"`
+}
+
+// EmailAddressesRequest sets a customer's email addresses. Unlike
+// models.EmailAddresses, empty addresses are omitted so an update doesn't
+// blank the ones the caller left out.
+type EmailAddressesRequest struct {
+	Orders     string `json:"orders,omitempty"`
+	Dispatches string `json:"dispatches,omitempty"`
+	Invoices   string `json:"invoices,omitempty"`
+}
+
+// ValidateCreate checks that a new customer has everything Orderspace and
+// our reminder emails need
+func (b *CustomerRequestBody) ValidateCreate() error {
+	if b.CompanyName == "" {
+		return errors.New("company_name is required")
+	}
+	if b.EmailAddresses == nil || b.EmailAddresses.Orders == "" {
+		return errors.New("email_addresses.orders is required")
+	}
+	return b.validate()
+}
+
+// ValidateUpdate checks the fields supplied for an update
+func (b *CustomerRequestBody) ValidateUpdate() error {
+	return b.validate()
+}
+
+func (b *CustomerRequestBody) validate() error {
+	if b.Status != "" && !models.CustomerStatus(b.Status).Validate() {
+		return fmt.Errorf("invalid status %q", b.Status)
+	}
+	if b.MinimumSpend != nil && *b.MinimumSpend < 0 {
+		return errors.New("minimum_spend cannot be negative")
+	}
+
+	if b.EmailAddresses != nil {
+		emails := map[string]string{
+			"orders":     b.EmailAddresses.Orders,
+			"dispatches": b.EmailAddresses.Dispatches,
+			"invoices":   b.EmailAddresses.Invoices,
+		}
+		for field, address := range emails {
+			if address == "" {
+				continue
+			}
+			if _, err := mail.ParseAddress(address); err != nil {
+				return fmt.Errorf("email_addresses.%s is not a valid email address", field)
+			}
+		}
+	}
+
+	for i, buyer := range b.Buyers {
+		if buyer.Name == "" {
+			return fmt.Errorf("buyers[%d].name is required", i)
+		}
+		if _, err := mail.ParseAddress(buyer.EmailAddress); err != nil {
+			return fmt.Errorf("buyers[%d].email_address is not a valid email address", i)
+		}
+	}
+
+	for i, address := range b.Addresses {
+		switch {
+		case address.Line1 == "":
+			return fmt.Errorf("addresses[%d].line1 is required", i)
+		case address.City == "":
+			return fmt.Errorf("addresses[%d].city is required", i)
+		case address.PostalCode == "":
+			return fmt.Errorf("addresses[%d].postal_code is required", i)
+		case address.Country == "":
+			return fmt.Errorf("addresses[%d].country is required", i)
+		}
+	}
+
+	return nil
+}
+
+// CreateCustomer sends a request to create a new customer
+func (c *Client) CreateCustomer(req *CustomerRequest) (*models.Customer, error) {
+	return c.CreateCustomerContext(context.Background(), req)
+}
+
+// CreateCustomerContext is CreateCustomer bound to ctx
+func (c *Client) CreateCustomerContext(ctx context.Context, req *CustomerRequest) (*models.Customer, error) {
+	if err := req.Customer.ValidateCreate(); err != nil {
+		return nil, fmt.Errorf("invalid customer: %w", err)
+	}
+
+	return c.sendCustomer(ctx, "POST", "/customers", req)
+}
+
+// UpdateCustomer sends a request to update an existing customer
+func (c *Client) UpdateCustomer(id string, req *CustomerRequest) (*models.Customer, error) {
+	return c.UpdateCustomerContext(context.Background(), id, req)
+}
+
+// UpdateCustomerContext is UpdateCustomer bound to ctx
+func (c *Client) UpdateCustomerContext(ctx context.Context, id string, req *CustomerRequest) (*models.Customer, error) {
+	if id == "" {
+		return nil, errors.New("customer id is required")
+	}
+	if err := req.Customer.ValidateUpdate(); err != nil {
+		return nil, fmt.Errorf("invalid customer: %w", err)
+	}
+
+	return c.sendCustomer(ctx, "PUT", "/customers/"+url.PathEscape(id), req)
+}
+
+// sendCustomer writes a customer request and decodes the returned customer
+func (c *Client) sendCustomer(ctx context.Context, method, path string, req *CustomerRequest) (*models.Customer, error) {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling request: %w", err)
+	}
+
+	resp, err := c.MakeAuthenticatedRequestContext(ctx, method, path, jsonData)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return nil, newAPIError(resp)
+	}
+
+	var result struct {
+		Customer models.Customer `json:"customer"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return &result.Customer, nil
+}
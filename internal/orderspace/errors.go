@@ -0,0 +1,105 @@
+package orderspace
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxErrorBody caps how much of an error response is kept on an APIError
+const maxErrorBody = 64 << 10
+
+// APIError is returned when Orderspace answers with an unexpected status
+type APIError struct {
+	StatusCode int    `json:"status_code"`
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	RequestID  string `json:"request_id,omitempty"`
+	// Message is the error message decoded from the response body, if any
+	Message string `json:"message,omitempty"`
+	// Errors holds field-level validation details when Orderspace sends them
+	Errors json.RawMessage `json:"errors,omitempty"`
+	// Body is the raw response body, truncated to 64KB
+	Body string `json:"-"`
+}
+
+func (e *APIError) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = http.StatusText(e.StatusCode)
+	}
+	if e.RequestID != "" {
+		return fmt.Sprintf("orderspace: %s %s: %d %s (request %s)", e.Method, e.Path, e.StatusCode, msg, e.RequestID)
+	}
+	return fmt.Sprintf("orderspace: %s %s: %d %s", e.Method, e.Path, e.StatusCode, msg)
+}
+
+// newAPIError builds an APIError from a non-success response. It consumes
+// but does not close the body.
+func newAPIError(resp *http.Response) *APIError {
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		RequestID:  resp.Header.Get("X-Request-Id"),
+	}
+	if resp.Request != nil {
+		apiErr.Method = resp.Request.Method
+		apiErr.Path = resp.Request.URL.Path
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	apiErr.Body = string(body)
+
+	var decoded struct {
+		Message string          `json:"message"`
+		Error   string          `json:"error"`
+		Errors  json.RawMessage `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &decoded); err == nil {
+		apiErr.Message = decoded.Message
+		if apiErr.Message == "" {
+			apiErr.Message = decoded.Error
+		}
+		apiErr.Errors = decoded.Errors
+	} else {
+		apiErr.Message = strings.TrimSpace(apiErr.Body)
+	}
+
+	return apiErr
+}
+
+// hasStatus reports whether err is an APIError with one of the given codes
+func hasStatus(err error, codes ...int) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	for _, code := range codes {
+		if apiErr.StatusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// IsNotFound reports whether err is an Orderspace 404
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+// IsRateLimited reports whether err is an Orderspace 429
+func IsRateLimited(err error) bool {
+	return hasStatus(err, http.StatusTooManyRequests)
+}
+
+// IsValidation reports whether Orderspace rejected the request body
+func IsValidation(err error) bool {
+	return hasStatus(err, http.StatusUnprocessableEntity, http.StatusBadRequest)
+}
+
+// IsUnauthorized reports whether Orderspace rejected our credentials
+func IsUnauthorized(err error) bool {
+	return hasStatus(err, http.StatusUnauthorized, http.StatusForbidden)
+}
@@ -1,7 +1,9 @@
 package orderspace
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -30,21 +32,25 @@ type OrderRequestBody struct {
 
 // OrderLineRequest represents an order line in the creation request
 type OrderLineRequest struct {
+	// ID identifies an existing line when updating an order; leave empty
+	// to add a new line
+	ID        string   `json:"id,omitempty"`
 	SKU       string   `json:"sku,omitempty"`
 	Name      string   `json:"name,omitempty"`
 	Quantity  int      `json:"quantity"`
 	UnitPrice *float64 `json:"unit_price,omitempty"`
 	Shipping  *bool    `json:"shipping,omitempty"`
 	TaxRateID *string  `json:"tax_rate_id,omitempty"`
-}
-
-// OrderError represents an error response from the API
-type OrderError struct {
-	Message string `json:"message"`
+	OnHold    *bool    `json:"on_hold,omitempty"`
 }
 
 // CreateOrder sends a request to create a new order
 func (c *Client) CreateOrder(req *OrderRequest) (*models.Order, error) {
+	return c.CreateOrderContext(context.Background(), req)
+}
+
+// CreateOrderContext is CreateOrder bound to ctx
+func (c *Client) CreateOrderContext(ctx context.Context, req *OrderRequest) (*models.Order, error) {
 	// Convert request to JSON
 	jsonData, err := json.Marshal(req)
 	if err != nil {
@@ -52,31 +58,25 @@ func (c *Client) CreateOrder(req *OrderRequest) (*models.Order, error) {
 	}
 
 	// Make authenticated request
-	resp, err := c.MakeAuthenticatedRequest("POST", "/orders", jsonData)
+	resp, err := c.MakeAuthenticatedRequestContext(ctx, "POST", "/orders", jsonData)
 	if err != nil {
 		return nil, fmt.Errorf("error making request: %w", err)
 	}
 	defer resp.Body.Close()
 
-	// Handle different response status codes
-	switch resp.StatusCode {
-	case http.StatusOK:
-		var successResp struct {
-			Order models.Order `json:"order"`
-		}
-		if err := json.NewDecoder(resp.Body).Decode(&successResp); err != nil {
-			return nil, fmt.Errorf("error decoding response: %w", err)
-		}
-		return &successResp.Order, nil
-	case http.StatusUnprocessableEntity:
-		var errorResp OrderError
-		if err := json.NewDecoder(resp.Body).Decode(&errorResp); err != nil {
-			return nil, fmt.Errorf("error decoding error response: %w", err)
-		}
-		return nil, fmt.Errorf("order validation failed: %s", errorResp.Message)
-	default:
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	// Validation failures come back as 422 and surface as an APIError
+	// that IsValidation recognises
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp)
 	}
+
+	var successResp struct {
+		Order models.Order `json:"order"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&successResp); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+	return &successResp.Order, nil
 }
 
 // Helper function to create a shipping line item
@@ -128,6 +128,11 @@ type OrderListResponse struct {
 }
 
 func (c *Client) ListOrders(params *OrderListParams) (*OrderListResponse, error) {
+	return c.ListOrdersContext(context.Background(), params)
+}
+
+// ListOrdersContext is ListOrders bound to ctx
+func (c *Client) ListOrdersContext(ctx context.Context, params *OrderListParams) (*OrderListResponse, error) {
 	basePath := "/orders"
 
 	if params != nil {
@@ -174,14 +179,14 @@ func (c *Client) ListOrders(params *OrderListParams) (*OrderListResponse, error)
 		basePath = fmt.Sprintf("%s?%s", basePath, q.Encode())
 	}
 
-	resp, err := c.MakeAuthenticatedRequest("GET", basePath, nil)
+	resp, err := c.MakeAuthenticatedRequestContext(ctx, "GET", basePath, nil)
 	if err != nil {
 		return nil, fmt.Errorf("error making request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, newAPIError(resp)
 	}
 
 	var result OrderListResponse
@@ -191,3 +196,281 @@ func (c *Client) ListOrders(params *OrderListParams) (*OrderListResponse, error)
 
 	return &result, nil
 }
+
+// GetOrder retrieves a single order by ID
+func (c *Client) GetOrder(id string) (*models.Order, error) {
+	return c.GetOrderContext(context.Background(), id)
+}
+
+// GetOrderContext is GetOrder bound to ctx
+func (c *Client) GetOrderContext(ctx context.Context, id string) (*models.Order, error) {
+	resp, err := c.MakeAuthenticatedRequestContext(ctx, "GET", "/orders/"+url.PathEscape(id), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp)
+	}
+
+	var result struct {
+		Order models.Order `json:"order"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return &result.Order, nil
+}
+
+// EachOrder calls fn for every order matching params, following
+// starting_after until Orderspace reports there are no more pages
+func (c *Client) EachOrder(params *OrderListParams, fn func(models.Order) error) error {
+	return c.EachOrderContext(context.Background(), params, fn)
+}
+
+// EachOrderContext is EachOrder bound to ctx
+func (c *Client) EachOrderContext(ctx context.Context, params *OrderListParams, fn func(models.Order) error) error {
+	var page OrderListParams
+	if params != nil {
+		page = *params
+	}
+
+	for {
+		resp, err := c.ListOrdersContext(ctx, &page)
+		if err != nil {
+			return err
+		}
+
+		for _, order := range resp.Orders {
+			if err := fn(order); err != nil {
+				if stopped(err) {
+					return nil
+				}
+				return err
+			}
+		}
+
+		if !resp.HasMore || len(resp.Orders) == 0 {
+			return nil
+		}
+		page.StartingAfter = resp.Orders[len(resp.Orders)-1].ID
+	}
+}
+
+// AllOrders collects every order matching params. A positive maxResults
+// caps how many are returned; zero means no cap.
+func (c *Client) AllOrders(params *OrderListParams, maxResults int) ([]models.Order, error) {
+	return c.AllOrdersContext(context.Background(), params, maxResults)
+}
+
+// AllOrdersContext is AllOrders bound to ctx
+func (c *Client) AllOrdersContext(ctx context.Context, params *OrderListParams, maxResults int) ([]models.Order, error) {
+	var orders []models.Order
+	err := c.EachOrderContext(ctx, params, func(order models.Order) error {
+		orders = append(orders, order)
+		if maxResults > 0 && len(orders) >= maxResults {
+			return ErrStopIteration
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// OrderUpdateRequest represents the structure of an order update request
+type OrderUpdateRequest struct {
+	Order OrderUpdateBody `json:"order"`
+}
+
+// OrderUpdateBody holds the order fields to change. Empty fields are left
+// untouched. When OrderLines is set it replaces the order's lines, so
+// existing lines that should be kept must be included by ID.
+type OrderUpdateBody struct {
+	Status           string             `json:"status,omitempty"`
+	DeliveryDate     string             `json:"delivery_date,omitempty"` // Format: "2006-01-02"
+	Reference        string             `json:"reference,omitempty"`
+	InternalNote     string             `json:"internal_note,omitempty"`
+	CustomerPONumber string             `json:"customer_po_number,omitempty"`
+	CustomerNote     string             `json:"customer_note,omitempty"`
+	ShippingAddress  *models.Address    `json:"shipping_address,omitempty"`
+	BillingAddress   *models.Address    `json:"billing_address,omitempty"`
+	OrderLines       []OrderLineRequest `json:"order_lines,omitempty"`
+}
+
+// Validate checks an update before it is sent to Orderspace
+func (b *OrderUpdateBody) Validate() error {
+	if b.Status != "" && !models.OrderStatus(b.Status).Validate() {
+		return fmt.Errorf("invalid status %q", b.Status)
+	}
+	if b.DeliveryDate != "" {
+		if _, err := time.Parse("2006-01-02", b.DeliveryDate); err != nil {
+			return errors.New("delivery_date must be formatted as YYYY-MM-DD")
+		}
+	}
+	for i, line := range b.OrderLines {
+		if err := line.validate(); err != nil {
+			return fmt.Errorf("order_lines[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (l OrderLineRequest) validate() error {
+	if l.Quantity < 0 {
+		return errors.New("quantity cannot be negative")
+	}
+	if l.ID == "" {
+		if l.SKU == "" && l.Name == "" {
+			return errors.New("new lines need a sku or name")
+		}
+		if l.Quantity == 0 {
+			return errors.New("new lines need a positive quantity")
+		}
+	}
+	return nil
+}
+
+// ErrOrderLineNotFound is returned when a line edit names a line the
+// order doesn't have
+var ErrOrderLineNotFound = errors.New("orderspace: order line not found")
+
+// OrderLineChange describes an edit to a single existing order line
+type OrderLineChange struct {
+	Quantity *int  `json:"quantity,omitempty"`
+	OnHold   *bool `json:"on_hold,omitempty"`
+}
+
+// UpdateOrder sends a request to update an existing order
+func (c *Client) UpdateOrder(id string, req *OrderUpdateRequest) (*models.Order, error) {
+	return c.UpdateOrderContext(context.Background(), id, req)
+}
+
+// UpdateOrderContext is UpdateOrder bound to ctx
+func (c *Client) UpdateOrderContext(ctx context.Context, id string, req *OrderUpdateRequest) (*models.Order, error) {
+	if id == "" {
+		return nil, errors.New("order id is required")
+	}
+	if err := req.Order.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid order update: %w", err)
+	}
+
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling request: %w", err)
+	}
+
+	resp, err := c.MakeAuthenticatedRequestContext(ctx, "PUT", "/orders/"+url.PathEscape(id), jsonData)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Validation failures come back as 422, as with CreateOrder
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp)
+	}
+
+	var successResp struct {
+		Order models.Order `json:"order"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&successResp); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+	return &successResp.Order, nil
+}
+
+// CancelOrder marks an order as cancelled
+func (c *Client) CancelOrder(id string) (*models.Order, error) {
+	return c.CancelOrderContext(context.Background(), id)
+}
+
+// CancelOrderContext is CancelOrder bound to ctx
+func (c *Client) CancelOrderContext(ctx context.Context, id string) (*models.Order, error) {
+	return c.UpdateOrderContext(ctx, id, &OrderUpdateRequest{
+		Order: OrderUpdateBody{Status: string(models.OrderStatusCancelled)},
+	})
+}
+
+// AddOrderLines appends lines to an existing order, keeping its current lines
+func (c *Client) AddOrderLines(orderID string, lines ...OrderLineRequest) (*models.Order, error) {
+	return c.AddOrderLinesContext(context.Background(), orderID, lines...)
+}
+
+// AddOrderLinesContext is AddOrderLines bound to ctx
+func (c *Client) AddOrderLinesContext(ctx context.Context, orderID string, lines ...OrderLineRequest) (*models.Order, error) {
+	if len(lines) == 0 {
+		return nil, errors.New("at least one order line is required")
+	}
+	for i, line := range lines {
+		if line.ID != "" {
+			return nil, fmt.Errorf("order_lines[%d]: new lines cannot have an id", i)
+		}
+	}
+
+	order, err := c.GetOrderContext(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.UpdateOrderContext(ctx, orderID, &OrderUpdateRequest{
+		Order: OrderUpdateBody{OrderLines: append(existingLines(order), lines...)},
+	})
+}
+
+// UpdateOrderLine changes the quantity or hold flag of one line on an order
+func (c *Client) UpdateOrderLine(orderID, lineID string, change OrderLineChange) (*models.Order, error) {
+	return c.UpdateOrderLineContext(context.Background(), orderID, lineID, change)
+}
+
+// UpdateOrderLineContext is UpdateOrderLine bound to ctx
+func (c *Client) UpdateOrderLineContext(ctx context.Context, orderID, lineID string, change OrderLineChange) (*models.Order, error) {
+	if change.Quantity == nil && change.OnHold == nil {
+		return nil, errors.New("nothing to change: set quantity or on_hold")
+	}
+
+	order, err := c.GetOrderContext(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := existingLines(order)
+	found := false
+	for i := range lines {
+		if lines[i].ID != lineID {
+			continue
+		}
+		if change.Quantity != nil {
+			lines[i].Quantity = *change.Quantity
+		}
+		if change.OnHold != nil {
+			lines[i].OnHold = change.OnHold
+		}
+		found = true
+	}
+	if !found {
+		return nil, fmt.Errorf("order %s line %s: %w", orderID, lineID, ErrOrderLineNotFound)
+	}
+
+	return c.UpdateOrderContext(ctx, orderID, &OrderUpdateRequest{
+		Order: OrderUpdateBody{OrderLines: lines},
+	})
+}
+
+// existingLines converts an order's current lines into update requests
+// that keep them as they are
+func existingLines(order *models.Order) []OrderLineRequest {
+	lines := make([]OrderLineRequest, 0, len(order.OrderLines))
+	for _, line := range order.OrderLines {
+		onHold := line.OnHold
+		lines = append(lines, OrderLineRequest{
+			ID:       line.ID,
+			Quantity: line.Quantity,
+			OnHold:   &onHold,
+		})
+	}
+	return lines
+}
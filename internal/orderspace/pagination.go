@@ -0,0 +1,12 @@
+package orderspace
+
+import "errors"
+
+// ErrStopIteration can be returned from an Each* callback to stop paging
+// early without the walk reporting an error
+var ErrStopIteration = errors.New("orderspace: stop iteration")
+
+// stopped reports whether a callback error should end iteration cleanly
+func stopped(err error) bool {
+	return errors.Is(err, ErrStopIteration)
+}
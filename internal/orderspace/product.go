@@ -1,6 +1,7 @@
 package orderspace
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -30,6 +31,11 @@ type ProductListParams struct {
 
 // ListProducts retrieves a list of products with optional filtering
 func (c *Client) ListProducts(params *ProductListParams) (*ProductsResponse, error) {
+	return c.ListProductsContext(context.Background(), params)
+}
+
+// ListProductsContext is ListProducts bound to ctx
+func (c *Client) ListProductsContext(ctx context.Context, params *ProductListParams) (*ProductsResponse, error) {
 	// Build base path and query parameters
 	basePath := "/products"
 
@@ -70,7 +76,7 @@ func (c *Client) ListProducts(params *ProductListParams) (*ProductsResponse, err
 	}
 
 	// Make authenticated request
-	resp, err := c.MakeAuthenticatedRequest("GET", basePath, nil)
+	resp, err := c.MakeAuthenticatedRequestContext(ctx, "GET", basePath, nil)
 	if err != nil {
 		return nil, fmt.Errorf("error making request: %w", err)
 	}
@@ -78,7 +84,7 @@ func (c *Client) ListProducts(params *ProductListParams) (*ProductsResponse, err
 
 	// Check response status
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, newAPIError(resp)
 	}
 
 	// Parse response
@@ -90,6 +96,90 @@ func (c *Client) ListProducts(params *ProductListParams) (*ProductsResponse, err
 	return &result, nil
 }
 
+// GetProduct retrieves a single product by ID
+func (c *Client) GetProduct(id string) (*models.Product, error) {
+	return c.GetProductContext(context.Background(), id)
+}
+
+// GetProductContext is GetProduct bound to ctx
+func (c *Client) GetProductContext(ctx context.Context, id string) (*models.Product, error) {
+	resp, err := c.MakeAuthenticatedRequestContext(ctx, "GET", "/products/"+url.PathEscape(id), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp)
+	}
+
+	var result struct {
+		Product models.Product `json:"product"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return &result.Product, nil
+}
+
+// EachProduct calls fn for every product matching params, following
+// starting_after until Orderspace reports there are no more pages
+func (c *Client) EachProduct(params *ProductListParams, fn func(models.Product) error) error {
+	return c.EachProductContext(context.Background(), params, fn)
+}
+
+// EachProductContext is EachProduct bound to ctx
+func (c *Client) EachProductContext(ctx context.Context, params *ProductListParams, fn func(models.Product) error) error {
+	var page ProductListParams
+	if params != nil {
+		page = *params
+	}
+
+	for {
+		resp, err := c.ListProductsContext(ctx, &page)
+		if err != nil {
+			return err
+		}
+
+		for _, product := range resp.Products {
+			if err := fn(product); err != nil {
+				if stopped(err) {
+					return nil
+				}
+				return err
+			}
+		}
+
+		if !resp.HasMore || len(resp.Products) == 0 {
+			return nil
+		}
+		page.StartingAfter = resp.Products[len(resp.Products)-1].ID
+	}
+}
+
+// AllProducts collects every product matching params. A positive
+// maxResults caps how many are returned; zero means no cap.
+func (c *Client) AllProducts(params *ProductListParams, maxResults int) ([]models.Product, error) {
+	return c.AllProductsContext(context.Background(), params, maxResults)
+}
+
+// AllProductsContext is AllProducts bound to ctx
+func (c *Client) AllProductsContext(ctx context.Context, params *ProductListParams, maxResults int) ([]models.Product, error) {
+	var products []models.Product
+	err := c.EachProductContext(ctx, params, func(product models.Product) error {
+		products = append(products, product)
+		if maxResults > 0 && len(products) >= maxResults {
+			return ErrStopIteration
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
 // Helper function to create boolean pointer
 func BoolPtr(b bool) *bool {
 	return &b
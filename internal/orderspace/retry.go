@@ -0,0 +1,130 @@
+package orderspace
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how idempotent requests are retried after rate
+// limiting, server errors and network failures
+type RetryPolicy struct {
+	// MaxAttempts is the total number of tries, including the first.
+	// Values below 2 disable retries.
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry; it doubles on
+	// each subsequent attempt up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// MaxElapsed caps the total time spent retrying a single request.
+	// Zero means no cap beyond MaxAttempts.
+	MaxElapsed time.Duration
+}
+
+// DefaultRetryPolicy returns the policy used by NewClient
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    4,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     30 * time.Second,
+		MaxElapsed:     2 * time.Minute,
+	}
+}
+
+// isIdempotent reports whether a request with this method is safe to replay
+func isIdempotent(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+// shouldRetry reports whether the outcome of an attempt is transient
+func (p RetryPolicy) shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		// Only transport failures are worth replaying; a cancelled or expired
+		// context is the caller giving up, not a blip
+		var urlErr *url.Error
+		if !errors.As(err, &urlErr) {
+			return false
+		}
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// backoff returns how long to wait before the given retry attempt (1-based),
+// preferring the server's Retry-After when it sent one
+func (p RetryPolicy) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if wait, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			return wait
+		}
+	}
+
+	wait := p.InitialBackoff
+	for i := 1; i < attempt && wait < p.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if p.MaxBackoff > 0 && wait > p.MaxBackoff {
+		wait = p.MaxBackoff
+	}
+	if wait <= 0 {
+		return 0
+	}
+
+	// Equal jitter: keep at least half the backoff, randomise the rest
+	half := wait / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// retryAfter parses a Retry-After header given as seconds or an HTTP date
+func retryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		wait := time.Until(at)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
+
+// doWithRetry runs send until it succeeds, fails permanently or the policy's
+// attempt and elapsed budgets are spent
+func (p RetryPolicy) doWithRetry(ctx context.Context, send func() (*http.Response, error)) (*http.Response, error) {
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		resp, err := send()
+		if attempt >= p.MaxAttempts || !p.shouldRetry(resp, err) {
+			return resp, err
+		}
+
+		wait := p.backoff(attempt, resp)
+		if p.MaxElapsed > 0 && time.Since(start)+wait > p.MaxElapsed {
+			return resp, err
+		}
+
+		if resp != nil {
+			// Drain so the connection can be reused for the next attempt
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package orderspace
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"missing", "", 0, false},
+		{"seconds", "5", 5 * time.Second, true},
+		{"zero", "0", 0, true},
+		{"negative", "-1", 0, false},
+		{"garbage", "soon", 0, false},
+		{"past date", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := retryAfter(tt.value)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("retryAfter(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+
+	t.Run("future date", func(t *testing.T) {
+		got, ok := retryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+		if !ok || got <= 0 || got > time.Minute {
+			t.Errorf("retryAfter(future) = %v, %v, want up to a minute", got, ok)
+		}
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+
+	tests := []struct {
+		attempt int
+		full    time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{10, time.Second},
+	}
+	for _, tt := range tests {
+		// Jitter keeps between half and all of the backoff
+		for i := 0; i < 20; i++ {
+			got := p.backoff(tt.attempt, nil)
+			if got < tt.full/2 || got > tt.full {
+				t.Fatalf("backoff(%d) = %v, want between %v and %v", tt.attempt, got, tt.full/2, tt.full)
+			}
+		}
+	}
+
+	t.Run("Retry-After wins", func(t *testing.T) {
+		resp := &http.Response{Header: http.Header{"Retry-After": []string{"7"}}}
+		if got := p.backoff(1, resp); got != 7*time.Second {
+			t.Errorf("backoff with Retry-After = %v, want 7s", got)
+		}
+	})
+
+	t.Run("zero backoff", func(t *testing.T) {
+		if got := (RetryPolicy{}).backoff(1, nil); got != 0 {
+			t.Errorf("backoff = %v, want 0", got)
+		}
+	})
+}
+
+func TestShouldRetry(t *testing.T) {
+	p := DefaultRetryPolicy()
+	transport := &url.Error{Op: "Get", URL: "https://example.com", Err: errors.New("connection reset")}
+
+	tests := []struct {
+		name   string
+		status int
+		err    error
+		want   bool
+	}{
+		{"ok", http.StatusOK, nil, false},
+		{"not found", http.StatusNotFound, nil, false},
+		{"unauthorized", http.StatusUnauthorized, nil, false},
+		{"rate limited", http.StatusTooManyRequests, nil, true},
+		{"server error", http.StatusInternalServerError, nil, true},
+		{"unavailable", http.StatusServiceUnavailable, nil, true},
+		{"transport error", 0, transport, true},
+		{"cancelled", 0, &url.Error{Op: "Get", URL: "https://example.com", Err: context.Canceled}, false},
+		{"deadline", 0, &url.Error{Op: "Get", URL: "https://example.com", Err: context.DeadlineExceeded}, false},
+		{"other error", 0, errors.New("failed to create request"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp *http.Response
+			if tt.err == nil {
+				resp = &http.Response{StatusCode: tt.status}
+			}
+			if got := p.shouldRetry(resp, tt.err); got != tt.want {
+				t.Errorf("shouldRetry() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// sequence returns a send func that answers with the given statuses in
+// turn, repeating the last one, and counts the calls
+func sequence(calls *int, statuses ...int) func() (*http.Response, error) {
+	return func() (*http.Response, error) {
+		status := statuses[len(statuses)-1]
+		if *calls < len(statuses) {
+			status = statuses[*calls]
+		}
+		*calls++
+		return &http.Response{
+			StatusCode: status,
+			Header:     http.Header{},
+			Body:       io.NopCloser(strings.NewReader("")),
+		}, nil
+	}
+}
+
+func TestDoWithRetry(t *testing.T) {
+	fast := RetryPolicy{MaxAttempts: 4, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+	tests := []struct {
+		name       string
+		policy     RetryPolicy
+		statuses   []int
+		wantStatus int
+		wantCalls  int
+	}{
+		{"success first time", fast, []int{200}, 200, 1},
+		{"recovers", fast, []int{503, 429, 200}, 200, 3},
+		{"gives up after max attempts", fast, []int{503}, 503, 4},
+		{"permanent error not retried", fast, []int{404}, 404, 1},
+		{"retries disabled", RetryPolicy{MaxAttempts: 1}, []int{503, 200}, 503, 1},
+		{"elapsed budget spent", RetryPolicy{MaxAttempts: 4, InitialBackoff: time.Hour, MaxBackoff: time.Hour, MaxElapsed: time.Second}, []int{503, 200}, 503, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			resp, err := tt.policy.doWithRetry(context.Background(), sequence(&calls, tt.statuses...))
+			if err != nil {
+				t.Fatalf("doWithRetry() error = %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus || calls != tt.wantCalls {
+				t.Errorf("doWithRetry() = %d after %d calls, want %d after %d", resp.StatusCode, calls, tt.wantStatus, tt.wantCalls)
+			}
+		})
+	}
+
+	t.Run("cancelled while waiting", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		slow := RetryPolicy{MaxAttempts: 4, InitialBackoff: time.Hour, MaxBackoff: time.Hour}
+		calls := 0
+		send := sequence(&calls, 503)
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			cancel()
+		}()
+
+		_, err := slow.doWithRetry(ctx, send)
+		if !errors.Is(err, context.Canceled) || calls != 1 {
+			t.Errorf("doWithRetry() = %v after %d calls, want context.Canceled after 1", err, calls)
+		}
+	})
+}
+
+// testClient returns a client pointed at srv with a valid token, so no
+// request goes to the real identity endpoint
+func testClient(t *testing.T, srv *httptest.Server) *Client {
+	t.Helper()
+	store := NewMemoryTokenStore()
+	store.Save(context.Background(), TokenInfo{Token: "token", CreatedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)})
+	client, err := NewClient("id", "secret", store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.BaseURL = srv.URL
+	client.Retry = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	return client
+}
+
+func TestClientRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		wantStatus int
+		wantCalls  int32
+	}{
+		{"GET is retried", http.MethodGet, http.StatusOK, 2},
+		{"POST is not retried", http.MethodPost, http.StatusServiceUnavailable, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if calls.Add(1) == 1 {
+					w.Header().Set("Retry-After", "0")
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer srv.Close()
+
+			resp, err := testClient(t, srv).MakeAuthenticatedRequest(tt.method, "/orders", nil)
+			if err != nil {
+				t.Fatalf("MakeAuthenticatedRequest() error = %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus || calls.Load() != tt.wantCalls {
+				t.Errorf("got %d after %d calls, want %d after %d", resp.StatusCode, calls.Load(), tt.wantStatus, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package orderspace
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// ErrNoToken is returned by a TokenStore that has nothing saved yet
+var ErrNoToken = errors.New("orderspace: no stored token")
+
+// TokenStore persists access tokens between requests and restarts
+type TokenStore interface {
+	// Latest returns the most recently saved token, or ErrNoToken
+	Latest(ctx context.Context) (TokenInfo, error)
+	// Save records a newly issued token
+	Save(ctx context.Context, token TokenInfo) error
+}
+
+// MemoryTokenStore keeps the current token in memory. Tokens are lost on
+// restart, which suits tests and one-off CLI runs.
+type MemoryTokenStore struct {
+	mu    sync.Mutex
+	token *TokenInfo
+}
+
+// NewMemoryTokenStore creates an empty in-memory token store
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{}
+}
+
+// Latest returns the saved token, or ErrNoToken
+func (s *MemoryTokenStore) Latest(ctx context.Context) (TokenInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token == nil {
+		return TokenInfo{}, ErrNoToken
+	}
+	return *s.token, nil
+}
+
+// Save replaces the saved token
+func (s *MemoryTokenStore) Save(ctx context.Context, token TokenInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.token = &token
+	return nil
+}
+
+// encryptedTokenPrefix marks access_token values sealed with the store's key
+const encryptedTokenPrefix = "enc:v1:"
+
+// defaultTokenRetention is how long superseded tokens are kept around
+const defaultTokenRetention = 24 * time.Hour
+
+// SQLiteTokenStore persists tokens in the tokens table created by the
+// database migrations, optionally encrypting them at rest and pruning rows
+// older than the retention window
+type SQLiteTokenStore struct {
+	db        *sql.DB
+	retention time.Duration
+	key       []byte
+	aead      cipher.AEAD
+}
+
+// SQLiteTokenStoreOption configures a SQLiteTokenStore
+type SQLiteTokenStoreOption func(*SQLiteTokenStore)
+
+// WithTokenRetention sets how long superseded tokens are kept. The newest
+// token is never pruned. Zero disables pruning.
+func WithTokenRetention(retention time.Duration) SQLiteTokenStoreOption {
+	return func(s *SQLiteTokenStore) {
+		s.retention = retention
+	}
+}
+
+// WithTokenEncryptionKey encrypts tokens at rest with AES-GCM. The key must
+// be 16, 24 or 32 bytes; an empty key leaves tokens in plaintext.
+func WithTokenEncryptionKey(key []byte) SQLiteTokenStoreOption {
+	return func(s *SQLiteTokenStore) {
+		s.key = key
+	}
+}
+
+// NewSQLiteTokenStore creates a token store backed by db
+func NewSQLiteTokenStore(db *sql.DB, opts ...SQLiteTokenStoreOption) (*SQLiteTokenStore, error) {
+	store := &SQLiteTokenStore{
+		db:        db,
+		retention: defaultTokenRetention,
+	}
+
+	for _, opt := range opts {
+		opt(store)
+	}
+
+	if len(store.key) > 0 {
+		block, err := aes.NewCipher(store.key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid token encryption key: %w", err)
+		}
+		store.aead, err = cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("creating token cipher: %w", err)
+		}
+	}
+
+	return store, nil
+}
+
+// Latest loads the most recently stored token
+func (s *SQLiteTokenStore) Latest(ctx context.Context) (TokenInfo, error) {
+	var token TokenInfo
+	var expiresAt sql.NullTime
+	err := s.db.QueryRowContext(ctx, `
+        SELECT access_token, created_at, expires_at
+        FROM tokens 
+        ORDER BY created_at DESC 
+        LIMIT 1
+    `).Scan(&token.Token, &token.CreatedAt, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return TokenInfo{}, ErrNoToken
+	}
+	if err != nil {
+		return TokenInfo{}, err
+	}
+
+	token.Token, err = s.open(token.Token)
+	if err != nil {
+		return TokenInfo{}, err
+	}
+
+	if expiresAt.Valid {
+		token.ExpiresAt = expiresAt.Time
+	} else {
+		// Rows stored before expiry tracking used a fixed 25 minute window
+		token.ExpiresAt = token.CreatedAt.Add(25*time.Minute + tokenExpirySkew)
+	}
+	return token, nil
+}
+
+// Save stores a new token and prunes rows past the retention window
+func (s *SQLiteTokenStore) Save(ctx context.Context, token TokenInfo) error {
+	sealed, err := s.seal(token.Token)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+        INSERT INTO tokens (access_token, created_at, expires_at) 
+        VALUES (?, ?, ?)
+    `, sealed, token.CreatedAt, token.ExpiresAt)
+	if err != nil {
+		return err
+	}
+
+	if s.retention <= 0 {
+		return nil
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+        DELETE FROM tokens
+        WHERE created_at < ?
+          AND id <> (SELECT id FROM tokens ORDER BY created_at DESC LIMIT 1)
+    `, time.Now().Add(-s.retention))
+	if err != nil {
+		return fmt.Errorf("pruning tokens: %w", err)
+	}
+	return nil
+}
+
+// seal encrypts a token for storage when a key is configured
+func (s *SQLiteTokenStore) seal(token string) (string, error) {
+	if s.aead == nil {
+		return token, nil
+	}
+
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("generating nonce: %w", err)
+	}
+
+	sealed := s.aead.Seal(nonce, nonce, []byte(token), nil)
+	return encryptedTokenPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// open reverses seal. Plaintext rows written before encryption was enabled
+// are returned unchanged.
+func (s *SQLiteTokenStore) open(stored string) (string, error) {
+	if !strings.HasPrefix(stored, encryptedTokenPrefix) {
+		return stored, nil
+	}
+	if s.aead == nil {
+		return "", fmt.Errorf("stored token is encrypted but no key is configured")
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, encryptedTokenPrefix))
+	if err != nil {
+		return "", fmt.Errorf("decoding stored token: %w", err)
+	}
+
+	nonceSize := s.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return "", fmt.Errorf("stored token is too short")
+	}
+
+	plain, err := s.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypting stored token: %w", err)
+	}
+	return string(plain), nil
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/DukeRupert/rr/internal/models"
+	"github.com/DukeRupert/rr/internal/templates"
+)
+
+// ErrInvalidTemplate is returned when an ad-hoc email's subject or bodies
+// don't render, for example because they use an unknown merge field
+var ErrInvalidTemplate = errors.New("invalid email template")
+
+// AdHocEmail is a one-off message sent to every recently active customer.
+// The subject and bodies are templates, rendered for each recipient inside
+// the shared layout, so they can use merge fields like {{.CompanyName}}.
+// An HtmlBody that is a full document only contributes what is inside its
+// <body>.
+type AdHocEmail struct {
+	Subject  string
+	HtmlBody string
+	TextBody string
+	Category models.NotificationCategory
+}
+
+func (msg AdHocEmail) content() models.EmailTemplate {
+	return models.EmailTemplate{
+		Subject:  msg.Subject,
+		HtmlBody: templates.BodyContent(msg.HtmlBody),
+		TextBody: msg.TextBody,
+	}
+}
+
+// SendAdHocEmail sends msg to every recently active customer who hasn't
+// opted out of its category. msg is checked before anything is sent or
+// recorded; if it doesn't render the error wraps ErrInvalidTemplate. Like
+// ReminderScheduler.Run, the send is detached from ctx's cancellation and
+// bounded by reminderRunTimeout.
+func (m *Mailer) SendAdHocEmail(ctx context.Context, msg AdHocEmail, opts RunOptions) (*SendSummary, error) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), reminderRunTimeout)
+	defer cancel()
+
+	layout, err := m.templates.Get(ctx, templates.LayoutName)
+	if err != nil {
+		return nil, fmt.Errorf("loading %s template: %w", templates.LayoutName, err)
+	}
+	if err := templates.Validate(*layout, msg.content()); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+	}
+
+	run, err := m.startRun(ctx, models.EmailRun{
+		Kind:       models.RunKindAdHoc,
+		Trigger:    opts.Trigger,
+		ScheduleID: opts.ScheduleID,
+		Category:   msg.Category,
+		Subject:    msg.Subject,
+	}, opts.DryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.sendAdHocEmail(ctx, run, msg, opts, *layout)
+	run.finish(ctx, err)
+	return run.summary, err
+}
+
+func (m *Mailer) sendAdHocEmail(ctx context.Context, run *sendRun, msg AdHocEmail, opts RunOptions, layout models.EmailTemplate) error {
+	now := time.Now()
+	customers, err := m.recipients(ctx, run, opts.CustomerIDs, now)
+	if err != nil {
+		return err
+	}
+
+	for _, customer := range customers {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("sending ad-hoc email: %w", err)
+		}
+
+		to := customer.EmailAddresses.For(msg.Category)
+		notifyDays, err := m.prefs.Allowed(ctx, customer.ID, msg.Category, to)
+		if err != nil {
+			log.Printf("ERROR checking notification preference for %s: %v", customer.CompanyName, err)
+			run.failed(ctx, customer, to, "failed to check preferences")
+			continue
+		}
+
+		if !notifyDays {
+			run.skipped(ctx, customer, to, "notifications disabled")
+			continue
+		}
+
+		adHocEmail, err := m.compose(ctx, customer, msg.Category, to, layout, msg.content(), now)
+		if err != nil {
+			log.Printf("ERROR building ad-hoc email for %s: %v", customer.CompanyName, err)
+			run.failed(ctx, customer, to, err.Error())
+			continue
+		}
+
+		if run.dryRun {
+			run.render(customer, adHocEmail)
+			continue
+		}
+
+		resp, err := m.emailClient.SendEmail(adHocEmail)
+		if err != nil {
+			log.Printf("ERROR sending ad-hoc email to %s: %v", customer.CompanyName, err)
+			run.failed(ctx, customer, to, err.Error())
+		} else {
+			log.Printf("SUCCESS sent ad-hoc email to %s (%s)", customer.CompanyName, to)
+			run.sent(ctx, customer, to, resp.MessageID)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// reminderDue reports whether a customer who orders every intervalWeeks
+// weeks and last ordered at lastOrder is expected to order again within the
+// week after now, which is the week the reminder is asking them to order
+// for. Customers with no known orders are always due.
+func reminderDue(intervalWeeks int, lastOrder time.Time, hasOrdered bool, now time.Time) bool {
+	if !hasOrdered {
+		return true
+	}
+	nextOrder := lastOrder.AddDate(0, 0, 7*intervalWeeks)
+	return !nextOrder.After(now.AddDate(0, 0, 7))
+}
+
+// reminderCadence checks the customer's order interval against their last
+// order in the mirror. When they aren't due, reason explains why.
+func (m *Mailer) reminderCadence(ctx context.Context, customerID string, now time.Time) (due bool, reason string, err error) {
+	weeks, err := m.mirror.OrderInterval(ctx, customerID)
+	if err != nil {
+		return false, "", fmt.Errorf("loading order interval: %w", err)
+	}
+	last, ok, err := m.mirror.LastOrderDate(ctx, customerID)
+	if err != nil {
+		return false, "", fmt.Errorf("loading last order: %w", err)
+	}
+
+	if reminderDue(weeks, last, ok, now) {
+		return true, "", nil
+	}
+	return false, fmt.Sprintf("not due, orders every %d weeks, last ordered %s", weeks, last.Format("2006-01-02")), nil
+}
@@ -0,0 +1,352 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/DukeRupert/rr/internal/database"
+	"github.com/DukeRupert/rr/internal/email"
+	"github.com/DukeRupert/rr/internal/models"
+	"github.com/DukeRupert/rr/internal/orderspace"
+	"github.com/DukeRupert/rr/internal/templates"
+	"github.com/DukeRupert/rr/internal/unsubscribe"
+)
+
+const fromAddress = "info@rockabillyroasting.com"
+
+// RunOptions describe what started a bulk send, for the run history, and
+// optionally narrow who it goes to
+type RunOptions struct {
+	Trigger    models.RunTrigger
+	ScheduleID *int64
+
+	// CustomerIDs limits the send to these customers instead of everyone
+	// recently active
+	CustomerIDs []string
+
+	// DryRun renders each email and returns it in the summary instead of
+	// sending it. Nothing is written to the run history or ledger.
+	DryRun bool
+}
+
+// recipients returns the customers a bulk send goes to: the listed ones,
+// or every customer updated in the last six weeks. A listed customer that
+// can't be fetched, for example because it was deleted, is recorded as
+// failed in run rather than stopping the send.
+func (m *Mailer) recipients(ctx context.Context, run *sendRun, customerIDs []string, now time.Time) ([]models.Customer, error) {
+	if len(customerIDs) == 0 {
+		sixWeeksAgo := now.AddDate(0, 0, -42)
+		params := &orderspace.CustomerListParams{
+			UpdatedSince: &sixWeeksAgo,
+		}
+
+		customers, err := m.orderClient.AllCustomersContext(ctx, params, 0)
+		if err != nil {
+			return nil, fmt.Errorf("fetching customers: %w", err)
+		}
+		return customers, nil
+	}
+
+	customers := make([]models.Customer, 0, len(customerIDs))
+	for _, id := range customerIDs {
+		customer, err := m.orderClient.GetCustomerContext(ctx, id)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, fmt.Errorf("fetching customer %s: %w", id, ctxErr)
+		}
+		if err != nil {
+			log.Printf("ERROR fetching customer %s: %v", id, err)
+			// Without the customer record the ID is all we have to name them by
+			run.failed(ctx, models.Customer{ID: id, CompanyName: id}, "", "failed to fetch customer: "+err.Error())
+			continue
+		}
+		customers = append(customers, *customer)
+	}
+	return customers, nil
+}
+
+// SendSummary reports the outcome of sending one email to many customers.
+// RunID identifies the run in the history. In a dry run Sent counts the
+// emails that would have gone out and Emails holds them.
+type SendSummary struct {
+	RunID   int64           `json:"run_id,omitempty"`
+	DryRun  bool            `json:"dry_run,omitempty"`
+	Sent    int             `json:"sent"`
+	Failed  int             `json:"failed"`
+	Skipped int             `json:"skipped"`
+	Details []string        `json:"details"`
+	Emails  []RenderedEmail `json:"emails,omitempty"`
+}
+
+// RenderedEmail is the exact email a dry run would have sent a customer
+type RenderedEmail struct {
+	CustomerID  string      `json:"customer_id"`
+	CompanyName string      `json:"company_name"`
+	Email       email.Email `json:"email"`
+}
+
+// sendRun tallies a bulk send in its summary and records each recipient's
+// outcome in the run history. Recording failures are logged rather than
+// stopping the send. Runs with a campaign also keep the reminder ledger up
+// to date.
+type sendRun struct {
+	runs    *database.EmailRuns
+	summary *SendSummary
+
+	ledger   *database.ReminderLedger
+	campaign string
+	week     string
+
+	dryRun bool
+}
+
+// startRun records a new run, unless this is a dry run, and returns its
+// tracker
+func (m *Mailer) startRun(ctx context.Context, run models.EmailRun, dryRun bool) (*sendRun, error) {
+	r := &sendRun{
+		runs:     m.runs,
+		summary:  &SendSummary{DryRun: dryRun, Details: []string{}},
+		ledger:   m.ledger,
+		campaign: run.Campaign,
+		week:     run.DeliveryWeek,
+		dryRun:   dryRun,
+	}
+	if dryRun {
+		return r, nil
+	}
+
+	id, err := m.runs.Start(ctx, run)
+	if err != nil {
+		return nil, err
+	}
+	r.summary.RunID = id
+	return r, nil
+}
+
+// claim reserves the customer in the ledger before sending. It reports
+// false with the holding run's ID if they were already emailed for this
+// campaign and week. Runs without a campaign always claim, and dry runs
+// only look.
+func (r *sendRun) claim(ctx context.Context, customerID string) (bool, int64, error) {
+	if r.campaign == "" {
+		return true, 0, nil
+	}
+	if r.dryRun {
+		heldBy, found, err := r.ledger.Lookup(ctx, r.campaign, r.week, customerID)
+		return !found, heldBy, err
+	}
+	return r.ledger.Claim(ctx, r.campaign, r.week, customerID, r.summary.RunID)
+}
+
+// render keeps the email a dry run would have sent
+func (r *sendRun) render(customer models.Customer, e email.Email) {
+	r.summary.Sent++
+	r.summary.Details = append(r.summary.Details, "DRY RUN: "+customer.CompanyName+" ("+e.To+")")
+	r.summary.Emails = append(r.summary.Emails, RenderedEmail{
+		CustomerID:  customer.ID,
+		CompanyName: customer.CompanyName,
+		Email:       e,
+	})
+}
+
+func (r *sendRun) record(ctx context.Context, customer models.Customer, to, messageID, status, reason string) {
+	if r.dryRun {
+		return
+	}
+
+	err := r.runs.RecordEmail(context.WithoutCancel(ctx), models.SentEmail{
+		RunID:        r.summary.RunID,
+		CustomerID:   customer.ID,
+		CompanyName:  customer.CompanyName,
+		EmailAddress: to,
+		MessageID:    messageID,
+		Status:       status,
+		Error:        reason,
+	})
+	if err != nil {
+		log.Printf("ERROR %v", err)
+	}
+}
+
+func (r *sendRun) sent(ctx context.Context, customer models.Customer, to, messageID string) {
+	r.summary.Sent++
+	r.summary.Details = append(r.summary.Details, "SUCCESS: "+customer.CompanyName+" ("+to+")")
+	r.record(ctx, customer, to, messageID, models.SentEmailSent, "")
+
+	if r.campaign != "" {
+		if err := r.ledger.MarkSent(context.WithoutCancel(ctx), r.campaign, r.week, customer.ID, r.summary.RunID); err != nil {
+			log.Printf("ERROR %v", err)
+		}
+	}
+}
+
+func (r *sendRun) failed(ctx context.Context, customer models.Customer, to, reason string) {
+	r.summary.Failed++
+	r.summary.Details = append(r.summary.Details, "ERROR: "+customer.CompanyName+" ("+reason+")")
+	r.record(ctx, customer, to, "", models.SentEmailFailed, reason)
+
+	// Give up any claim so the next run retries this customer
+	if r.campaign != "" && !r.dryRun {
+		if err := r.ledger.Release(context.WithoutCancel(ctx), r.campaign, r.week, customer.ID, r.summary.RunID); err != nil {
+			log.Printf("ERROR %v", err)
+		}
+	}
+}
+
+func (r *sendRun) skipped(ctx context.Context, customer models.Customer, to, reason string) {
+	r.summary.Skipped++
+	r.summary.Details = append(r.summary.Details, "SKIPPED: "+customer.CompanyName+" ("+reason+")")
+	r.record(ctx, customer, to, "", models.SentEmailSkipped, reason)
+}
+
+// finish stores the final counts, marking the run failed if runErr is set.
+// It still writes when ctx has been cancelled, since that is often why the
+// run ended.
+func (r *sendRun) finish(ctx context.Context, runErr error) {
+	if r.dryRun {
+		return
+	}
+
+	s := r.summary
+	if err := r.runs.Finish(context.WithoutCancel(ctx), s.RunID, s.Sent, s.Failed, s.Skipped, runErr); err != nil {
+		log.Printf("ERROR %v", err)
+	}
+}
+
+// Mailer sends reminder and ad-hoc emails to customers, respecting their
+// notification preferences
+type Mailer struct {
+	db          *sql.DB
+	orderClient *orderspace.Client
+	emailClient email.Sender
+	prefs       *database.NotificationPreferences
+	mirror      *database.Mirror
+	runs        *database.EmailRuns
+	ledger      *database.ReminderLedger
+	templates   *database.EmailTemplates
+	signer      *unsubscribe.Signer
+	baseURL     string
+}
+
+// NewMailer creates a Mailer. baseURL is the public address of this
+// service, used to build unsubscribe links.
+func NewMailer(db *sql.DB, orderClient *orderspace.Client, emailClient email.Sender, signer *unsubscribe.Signer, baseURL string) *Mailer {
+	return &Mailer{
+		db:          db,
+		orderClient: orderClient,
+		emailClient: emailClient,
+		prefs:       database.NewNotificationPreferences(db),
+		mirror:      database.NewMirror(db),
+		runs:        database.NewEmailRuns(db),
+		ledger:      database.NewReminderLedger(db),
+		templates:   database.NewEmailTemplates(db),
+		signer:      signer,
+		baseURL:     strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+// unsubscribeURL returns the one-click link that opts this recipient out of
+// the category
+func (m *Mailer) unsubscribeURL(customerID string, category models.NotificationCategory, to string) (string, error) {
+	token, err := m.signer.Token(unsubscribe.Claims{
+		CustomerID:   customerID,
+		Category:     category,
+		EmailAddress: to,
+	})
+	if err != nil {
+		return "", fmt.Errorf("signing unsubscribe token: %w", err)
+	}
+	return m.baseURL + "/unsubscribe/" + url.PathEscape(token), nil
+}
+
+// loadTemplates fetches the layout and the named content template
+func (m *Mailer) loadTemplates(ctx context.Context, name string) (layout, content models.EmailTemplate, err error) {
+	l, err := m.templates.Get(ctx, templates.LayoutName)
+	if err != nil {
+		return layout, content, fmt.Errorf("loading %s template: %w", templates.LayoutName, err)
+	}
+	c, err := m.templates.Get(ctx, name)
+	if err != nil {
+		return layout, content, fmt.Errorf("loading %s template: %w", name, err)
+	}
+	return *l, *c, nil
+}
+
+// templateData gathers the template variables for one recipient
+func (m *Mailer) templateData(ctx context.Context, customer models.Customer, unsubscribeURL string, now time.Time) (templates.Data, error) {
+	start, _ := upcomingDeliveryWindow(now)
+	data := templates.Data{
+		CompanyName:      customer.CompanyName,
+		BuyerNames:       []string{},
+		OrderCutoff:      templates.FormatDate(orderCutoff(start)),
+		NextDeliveryDate: templates.FormatDate(start),
+		UnsubscribeURL:   unsubscribeURL,
+	}
+	for _, buyer := range customer.Buyers {
+		if buyer.Name != "" {
+			data.BuyerNames = append(data.BuyerNames, buyer.Name)
+		}
+	}
+
+	order, err := m.mirror.LastOrder(ctx, customer.ID)
+	if err != nil {
+		return data, err
+	}
+	if order != nil {
+		data.LastOrderSummary = orderSummary(*order)
+		data.LastOrderNumber = order.Number
+		data.LastOrderDate = templates.FormatDate(order.Created)
+	}
+	return data, nil
+}
+
+// orderSummary describes an order in one line for templates, e.g.
+// "#1042 on October 5: 2 x House Blend, 1 x Decaf"
+func orderSummary(order models.Order) string {
+	var lines []string
+	for _, line := range order.OrderLines {
+		if line.Shipping {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%d x %s", line.Quantity, line.Name))
+	}
+
+	summary := fmt.Sprintf("#%d on %s", order.Number, order.Created.Format("January 2"))
+	if len(lines) > 0 {
+		summary += ": " + strings.Join(lines, ", ")
+	}
+	return summary
+}
+
+// compose renders content in the layout for one recipient and adds the
+// List-Unsubscribe headers for the category
+func (m *Mailer) compose(ctx context.Context, customer models.Customer, category models.NotificationCategory, to string, layout, content models.EmailTemplate, now time.Time) (email.Email, error) {
+	link, err := m.unsubscribeURL(customer.ID, category, to)
+	if err != nil {
+		return email.Email{}, err
+	}
+	data, err := m.templateData(ctx, customer, link, now)
+	if err != nil {
+		return email.Email{}, err
+	}
+	rendered, err := templates.Render(layout, content, data)
+	if err != nil {
+		return email.Email{}, err
+	}
+
+	return email.Email{
+		From:     fromAddress,
+		To:       to,
+		Subject:  rendered.Subject,
+		HtmlBody: rendered.HTML,
+		TextBody: rendered.Text,
+		Headers: []email.Header{
+			{Name: "List-Unsubscribe", Value: "<" + link + ">"},
+			{Name: "List-Unsubscribe-Post", Value: "List-Unsubscribe=One-Click"},
+		},
+	}, nil
+}
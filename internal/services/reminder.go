@@ -1,22 +1,55 @@
 package services
 
 import (
-	"database/sql"
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/DukeRupert/rr/internal/database"
 	"github.com/DukeRupert/rr/internal/email"
-	"github.com/DukeRupert/rr/internal/orderspace"
+	"github.com/DukeRupert/rr/internal/models"
+	"github.com/DukeRupert/rr/internal/templates"
 	"github.com/go-co-op/gocron/v2"
+	"github.com/robfig/cron/v3"
 )
 
+// ErrCannotRetry is returned when a run has nothing that can be retried
+var ErrCannotRetry = errors.New("run cannot be retried")
+
+// reminderRunTimeout bounds a single reminder or ad-hoc run so a hung
+// Orderspace call can't pin the job forever
+const reminderRunTimeout = 10 * time.Minute
+
+// reminderCampaign keys order reminders in the ledger; together with the
+// delivery week it identifies one week's reminder
+const reminderCampaign = "order_reminder"
+
+// reminderWeek is the ledger key for the delivery week a reminder sent at
+// now is for
+func reminderWeek(now time.Time) string {
+	start, _ := upcomingDeliveryWindow(now)
+	return start.Format("2006-01-02")
+}
+
+// reminderJobTag marks the gocron jobs created from reminder schedules so a
+// reload can replace them without touching the sync job
+const reminderJobTag = "order-reminder"
+
 type ReminderScheduler struct {
 	scheduler gocron.Scheduler
+	mailer    *Mailer
+	schedules *database.ReminderSchedules
+
+	// mu serialises reloads; jobs maps schedule IDs to their current job
+	mu   sync.Mutex
+	jobs map[int64]gocron.Job
 }
 
-func NewReminderScheduler(db *sql.DB, orderClient *orderspace.Client, emailClient email.Sender) (*ReminderScheduler, error) {
+func NewReminderScheduler(mailer *Mailer, schedules *database.ReminderSchedules) (*ReminderScheduler, error) {
 	mst, _ := time.LoadLocation("America/Denver")
 	log.Printf("Task running at: %v", time.Now().In(mst))
 
@@ -25,25 +58,174 @@ func NewReminderScheduler(db *sql.DB, orderClient *orderspace.Client, emailClien
 		return nil, fmt.Errorf("creating scheduler: %w", err)
 	}
 
-	_, err = s.NewJob(
-		gocron.WeeklyJob(
-			1,
+	rs := &ReminderScheduler{
+		scheduler: s,
+		mailer:    mailer,
+		schedules: schedules,
+		jobs:      map[int64]gocron.Job{},
+	}
+	if err := rs.Reload(context.Background()); err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
 
-			gocron.NewWeekdays(time.Friday),
-			gocron.NewAtTimes(gocron.NewAtTime(10, 00, 0))),
+// cronSpec turns a schedule into a crontab pinned to its timezone
+func cronSpec(schedule models.ReminderSchedule) string {
+	expr := schedule.CronExpression
+	if expr == "" {
+		at, _ := time.Parse("15:04", schedule.TimeOfDay)
+		expr = fmt.Sprintf("%d %d * * %d", at.Minute(), at.Hour(), int(*schedule.Weekday))
+	}
+	return "CRON_TZ=" + schedule.Timezone + " " + expr
+}
 
-		gocron.NewTask(
-			func() error {
-				log.Printf("Running scheduled order reminder task at: %v", time.Now())
-				return SendOrderReminders(db, orderClient, emailClient)
-			},
-		),
-	)
+// ValidateSchedule checks a schedule can be scheduled, including parsing
+// its cron expression
+func ValidateSchedule(schedule models.ReminderSchedule) error {
+	if err := schedule.Validate(); err != nil {
+		return err
+	}
+	if expr := strings.TrimSpace(schedule.CronExpression); strings.HasPrefix(expr, "TZ=") || strings.HasPrefix(expr, "CRON_TZ=") {
+		return fmt.Errorf("set the timezone field instead of a TZ prefix")
+	}
+	if _, err := cron.ParseStandard(cronSpec(schedule)); err != nil {
+		return fmt.Errorf("invalid cron_expression: %w", err)
+	}
+	return nil
+}
+
+// Reload replaces the scheduled reminder jobs with the enabled schedules
+// currently stored in the database
+func (rs *ReminderScheduler) Reload(ctx context.Context) error {
+	schedules, err := rs.schedules.List(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("creating reminder job: %w", err)
+		return fmt.Errorf("loading reminder schedules: %w", err)
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	rs.scheduler.RemoveByTags(reminderJobTag)
+	rs.jobs = map[int64]gocron.Job{}
+
+	for _, schedule := range schedules {
+		if !schedule.Enabled {
+			continue
+		}
+		if err := ValidateSchedule(schedule); err != nil {
+			log.Printf("ERROR skipping reminder schedule %d (%s): %v", schedule.ID, schedule.Name, err)
+			continue
+		}
+
+		job, err := rs.scheduler.NewJob(
+			gocron.CronJob(cronSpec(schedule), false),
+			gocron.NewTask(rs.runReminders, schedule.ID, schedule.Name),
+			gocron.WithName(schedule.Name),
+			gocron.WithTags(reminderJobTag),
+			gocron.WithSingletonMode(gocron.LimitModeReschedule),
+		)
+		if err != nil {
+			log.Printf("ERROR scheduling reminder schedule %d (%s): %v", schedule.ID, schedule.Name, err)
+			continue
+		}
+		rs.jobs[schedule.ID] = job
+	}
+
+	log.Printf("Loaded %d reminder schedule(s)", len(rs.jobs))
+	return nil
+}
+
+func (rs *ReminderScheduler) runReminders(scheduleID int64, name string) error {
+	log.Printf("Running scheduled order reminder task %q at: %v", name, time.Now())
+	_, err := rs.Run(context.Background(), RunOptions{
+		Trigger:    models.RunTriggerScheduled,
+		ScheduleID: &scheduleID,
+	})
+	return err
+}
+
+// Run sends order reminders now. Scheduled jobs and manual API triggers
+// both come through here. The run is detached from ctx's cancellation so
+// a dropped HTTP request doesn't abandon it half way, but is still bounded
+// by reminderRunTimeout.
+func (rs *ReminderScheduler) Run(ctx context.Context, opts RunOptions) (*SendSummary, error) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), reminderRunTimeout)
+	defer cancel()
+
+	summary, err := rs.mailer.SendOrderReminders(ctx, opts)
+	if err != nil {
+		return summary, err
+	}
+	log.Printf("Order reminders: %d sent, %d failed, %d skipped", summary.Sent, summary.Failed, summary.Skipped)
+	return summary, nil
+}
+
+// RetryFailed re-runs order reminders for the customers whose email failed
+// in an earlier run. The ledger still stops anyone who has since been
+// reminded from getting a second email.
+func (rs *ReminderScheduler) RetryFailed(ctx context.Context, runID int64) (*SendSummary, error) {
+	run, err := rs.mailer.runs.Get(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+	if run.Kind != models.RunKindOrderReminder {
+		return nil, fmt.Errorf("%w: only order reminder runs can be retried", ErrCannotRetry)
+	}
+	if week := reminderWeek(time.Now()); run.DeliveryWeek != week {
+		return nil, fmt.Errorf("%w: run %d was for the week of %s, not %s", ErrCannotRetry, runID, run.DeliveryWeek, week)
+	}
+
+	var customerIDs []string
+	for _, e := range run.Emails {
+		if e.Status == models.SentEmailFailed {
+			customerIDs = append(customerIDs, e.CustomerID)
+		}
+	}
+	if len(customerIDs) == 0 {
+		return nil, fmt.Errorf("%w: run %d has no failed emails", ErrCannotRetry, runID)
+	}
+
+	return rs.Run(ctx, RunOptions{
+		Trigger:     models.RunTriggerManual,
+		CustomerIDs: customerIDs,
+	})
+}
+
+// NextRun returns when the schedule will next fire, or nil if it isn't
+// currently scheduled
+func (rs *ReminderScheduler) NextRun(scheduleID int64) *time.Time {
+	rs.mu.Lock()
+	job, ok := rs.jobs[scheduleID]
+	rs.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	next, err := job.NextRun()
+	if err != nil || next.IsZero() {
+		return nil
 	}
+	return &next
+}
 
-	return &ReminderScheduler{scheduler: s}, nil
+// ScheduleSync runs the mirror sync every interval, starting immediately.
+// Runs never overlap; a slow sync delays the next one instead.
+func (rs *ReminderScheduler) ScheduleSync(sync *MirrorSync, interval time.Duration) error {
+	_, err := rs.scheduler.NewJob(
+		gocron.DurationJob(interval),
+		gocron.NewTask(func() error {
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			defer cancel()
+			return sync.runLogged(ctx)
+		}),
+		gocron.WithSingletonMode(gocron.LimitModeReschedule),
+		gocron.WithStartAt(gocron.WithStartImmediately()),
+	)
+	if err != nil {
+		return fmt.Errorf("creating sync job: %w", err)
+	}
+	return nil
 }
 
 func (rs *ReminderScheduler) Start() {
@@ -54,127 +236,151 @@ func (rs *ReminderScheduler) Shutdown() error {
 	return rs.scheduler.Shutdown()
 }
 
-func SendOrderReminders(db *sql.DB, orderClient *orderspace.Client, emailClient email.Sender) error {
-	log.Printf("Starting order reminders at: %s", time.Now().Format(time.RFC3339))
+// SendOrderReminders emails every recently active customer who hasn't opted
+// out of order reminders, whose order interval is due and who hasn't
+// already ordered for the upcoming delivery week
+func (m *Mailer) SendOrderReminders(ctx context.Context, opts RunOptions) (*SendSummary, error) {
+	now := time.Now()
+	log.Printf("Starting order reminders at: %s", now.Format(time.RFC3339))
 
-	sixWeeksAgo := time.Now().AddDate(0, 0, -42)
-	params := &orderspace.CustomerListParams{
-		UpdatedSince: &sixWeeksAgo,
+	layout, content, err := m.loadTemplates(ctx, templates.OrderReminderName)
+	if err != nil {
+		return nil, err
 	}
 
-	resp, err := orderClient.ListCustomers(params)
+	run, err := m.startRun(ctx, models.EmailRun{
+		Kind:         models.RunKindOrderReminder,
+		Trigger:      opts.Trigger,
+		ScheduleID:   opts.ScheduleID,
+		Campaign:     reminderCampaign,
+		DeliveryWeek: reminderWeek(now),
+		Category:     models.NotificationOrderReminders,
+		Subject:      content.Subject,
+	}, opts.DryRun)
 	if err != nil {
-		return fmt.Errorf("fetching customers: %w", err)
+		return nil, err
 	}
 
-	for _, customer := range resp.Customers {
-		var notifyDays bool
-		err := db.QueryRow(`
-           SELECT COALESCE(
-               (SELECT email_notify_days FROM customer_notifications WHERE customer_id = ?),
-               true
-           )
-       `, customer.ID).Scan(&notifyDays)
+	err = m.sendOrderReminders(ctx, run, opts, layout, content, now)
+	run.finish(ctx, err)
+
+	log.Printf("Completed order reminders at: %s", time.Now().Format(time.RFC3339))
+	return run.summary, err
+}
+
+func (m *Mailer) sendOrderReminders(ctx context.Context, run *sendRun, opts RunOptions, layout, content models.EmailTemplate, now time.Time) error {
+	customers, err := m.recipients(ctx, run, opts.CustomerIDs, now)
+	if err != nil {
+		return err
+	}
+
+	for _, customer := range customers {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("sending reminders: %w", err)
+		}
+
+		to := customer.EmailAddresses.For(models.NotificationOrderReminders)
+		notifyDays, err := m.prefs.Allowed(ctx, customer.ID, models.NotificationOrderReminders, to)
 		if err != nil {
 			log.Printf("ERROR checking notification preference for %s: %v", customer.CompanyName, err)
+			run.failed(ctx, customer, to, "failed to check preferences")
 			continue
 		}
 
 		if !notifyDays {
 			log.Printf("SKIPPED %s (notifications disabled)", customer.CompanyName)
+			run.skipped(ctx, customer, to, "notifications disabled")
 			continue
 		}
 
-		reminderEmail := email.Email{
-			From:     "info@rockabillyroasting.com",
-			To:       customer.EmailAddresses.Orders,
-			Subject:  "Time to Place Your Coffee Order!",
-			HtmlBody: generateReminderEmailHTML(customer.CompanyName),
-			TextBody: generateReminderEmailText(customer.CompanyName),
+		due, reason, err := m.reminderCadence(ctx, customer.ID, now)
+		if err != nil {
+			log.Printf("ERROR checking order interval for %s: %v", customer.CompanyName, err)
+			run.failed(ctx, customer, to, "failed to check order interval")
+			continue
+		}
+		if !due {
+			log.Printf("SKIPPED %s (%s)", customer.CompanyName, reason)
+			run.skipped(ctx, customer, to, reason)
+			continue
+		}
+
+		order, err := m.coveringOrder(ctx, customer.ID, now)
+		if err != nil {
+			log.Printf("ERROR checking upcoming orders for %s: %v", customer.CompanyName, err)
+			run.failed(ctx, customer, to, "failed to check upcoming orders")
+			continue
+		}
+		if order != nil {
+			reason := orderedReason(order)
+			log.Printf("SKIPPED %s (%s)", customer.CompanyName, reason)
+			run.skipped(ctx, customer, to, reason)
+			continue
+		}
+
+		reminderEmail, err := m.compose(ctx, customer, models.NotificationOrderReminders, to, layout, content, now)
+		if err != nil {
+			log.Printf("ERROR building reminder for %s: %v", customer.CompanyName, err)
+			run.failed(ctx, customer, to, err.Error())
+			continue
 		}
 
-		_, err = emailClient.SendEmail(reminderEmail)
+		claimed, heldBy, err := run.claim(ctx, customer.ID)
+		if err != nil {
+			log.Printf("ERROR checking reminder ledger for %s: %v", customer.CompanyName, err)
+			run.failed(ctx, customer, to, "failed to check reminder ledger")
+			continue
+		}
+		if !claimed {
+			reason := fmt.Sprintf("already sent this week in run #%d", heldBy)
+			log.Printf("SKIPPED %s (%s)", customer.CompanyName, reason)
+			run.skipped(ctx, customer, to, reason)
+			continue
+		}
+		if run.dryRun {
+			run.render(customer, reminderEmail)
+			continue
+		}
+
+		resp, err := m.emailClient.SendEmail(reminderEmail)
 		if err != nil {
 			log.Printf("ERROR sending reminder to %s: %v", customer.CompanyName, err)
+			run.failed(ctx, customer, to, err.Error())
 		} else {
-			log.Printf("SUCCESS sent reminder to %s (%s)", customer.CompanyName, customer.EmailAddresses.Orders)
+			log.Printf("SUCCESS sent reminder to %s (%s)", customer.CompanyName, to)
+			run.sent(ctx, customer, to, resp.MessageID)
 		}
 	}
 
-	log.Printf("Completed order reminders at: %s", time.Now().Format(time.RFC3339))
 	return nil
 }
 
-func generateReminderEmailHTML(companyName string) string {
-	return `
-        <html>
-            <body>
-                <h2>Hey guys!</h2>
-                <p>Just a friendly reminder from your coffee crew at Rockabilly Roasting over here in Washington State.</p>
-                <p>To keep your coffee delivery running smooth as a '57 Chevy, we kindly ask that you place your order by <strong>Friday afternoon</strong>. This helps us make sure your beans arrive right on schedule the following week.</p>
-                <p><a href="https://rockabillyroasting.orderspace.com/">Click here to place your order now!</a></p>
-                <p>Need anything else? Just hit reply - we're always happy to help!</p>
-                <p>Keep rockin',<br>
-                The Rockabilly Roasting Team</p>
-            </body>
-        </html>
-    `
-}
-
-func generateReminderEmailText(companyName string) string {
-	return `Hey guys!
-
-Just a friendly reminder from your coffee crew at Rockabilly Roasting over here in Washington State.
-
-To keep your coffee delivery running smooth as a '57 Chevy, we kindly ask that you place your order by Friday afternoon. This helps us make sure your beans arrive right on schedule the following week.
-
-Place your order here: https://rockabillyroasting.orderspace.com/
-
-Need anything else? Just hit reply - we're always happy to help!
-
-Keep rockin',
-The Rockabilly Roasting Team`
-}
-
-func PreviewOrderReminders(db *sql.DB, orderClient *orderspace.Client, emailClient email.Sender) error {
-	sixWeeksAgo := time.Now().AddDate(0, 0, -42)
-	params := &orderspace.CustomerListParams{
-		UpdatedSince: &sixWeeksAgo,
-	}
-
-	resp, err := orderClient.ListCustomers(params)
+// PreviewOrderReminders emails a summary of who would get this week's
+// reminder to the operator instead of the customers
+func (m *Mailer) PreviewOrderReminders(ctx context.Context) error {
+	summary, err := m.SendOrderReminders(ctx, RunOptions{
+		Trigger: models.RunTriggerManual,
+		DryRun:  true,
+	})
 	if err != nil {
-		return fmt.Errorf("fetching customers: %w", err)
-	}
-
-	var activeCustomers []string
-	for _, customer := range resp.Customers {
-		var notifyDays bool
-		err := db.QueryRow(`
-            SELECT COALESCE(
-                (SELECT email_notify_days FROM customer_notifications WHERE customer_id = ?),
-                true
-            )
-        `, customer.ID).Scan(&notifyDays)
-		if err != nil {
-			return fmt.Errorf("checking notification preference: %w", err)
-		}
+		return err
+	}
 
-		if notifyDays {
-			activeCustomers = append(activeCustomers, fmt.Sprintf("%s (%s)", customer.CompanyName, customer.EmailAddresses.Orders))
-		}
+	activeCustomers := make([]string, 0, len(summary.Emails))
+	for _, rendered := range summary.Emails {
+		activeCustomers = append(activeCustomers, fmt.Sprintf("%s (%s)", rendered.CompanyName, rendered.Email.To))
 	}
 
 	// Send preview email
 	previewEmail := email.Email{
-		From:     "info@rockabillyroasting.com",
+		From:     fromAddress,
 		To:       "logan@fireflysoftware.dev",
 		Subject:  fmt.Sprintf("Order Reminder Preview - %d Customers", len(activeCustomers)),
 		HtmlBody: generatePreviewEmailHTML(activeCustomers),
 		TextBody: generatePreviewEmailText(activeCustomers),
 	}
 
-	_, err = emailClient.SendEmail(previewEmail)
+	_, err = m.emailClient.SendEmail(previewEmail)
 	return err
 }
 
@@ -0,0 +1,259 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DukeRupert/rr/internal/database"
+	"github.com/DukeRupert/rr/internal/email"
+	"github.com/DukeRupert/rr/internal/models"
+	"github.com/DukeRupert/rr/internal/orderspace"
+	"github.com/DukeRupert/rr/internal/unsubscribe"
+)
+
+// fakeSender records every email and fails sends to the addresses in fail
+type fakeSender struct {
+	mu   sync.Mutex
+	fail map[string]bool
+	sent map[string]int
+}
+
+func (s *fakeSender) SendEmail(e email.Email) (*email.EmailResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail[e.To] {
+		return nil, errors.New("mail server unavailable")
+	}
+	if s.sent == nil {
+		s.sent = map[string]int{}
+	}
+	s.sent[e.To]++
+	return &email.EmailResponse{MessageID: fmt.Sprintf("%s-%d", e.To, s.sent[e.To])}, nil
+}
+
+var testCustomers = []models.Customer{
+	{ID: "c1", CompanyName: "Cafe One", Status: "active", EmailAddresses: models.EmailAddresses{Orders: "one@example.com"}},
+	{ID: "c2", CompanyName: "Cafe Two", Status: "active", EmailAddresses: models.EmailAddresses{Orders: "two@example.com"}},
+}
+
+// fakeOrderspace serves testCustomers and no orders
+func fakeOrderspace(t *testing.T) *orderspace.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/customers":
+			json.NewEncoder(w).Encode(map[string]interface{}{"customers": testCustomers, "has_more": false})
+		case strings.HasPrefix(r.URL.Path, "/customers/"):
+			id := strings.TrimPrefix(r.URL.Path, "/customers/")
+			for _, c := range testCustomers {
+				if c.ID == id {
+					json.NewEncoder(w).Encode(map[string]interface{}{"customer": c})
+					return
+				}
+			}
+			http.Error(w, `{"message":"not found"}`, http.StatusNotFound)
+		case r.URL.Path == "/orders":
+			json.NewEncoder(w).Encode(map[string]interface{}{"orders": []models.Order{}, "has_more": false})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	store := orderspace.NewMemoryTokenStore()
+	store.Save(context.Background(), orderspace.TokenInfo{Token: "token", CreatedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)})
+	client, err := orderspace.NewClient("id", "secret", store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.BaseURL = srv.URL
+	client.Retry = orderspace.RetryPolicy{}
+	return client
+}
+
+// testDB opens a private, migrated in-memory database
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	db, err := database.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	if _, err := database.Migrate(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func testMailer(t *testing.T, sender *fakeSender) (*Mailer, *sql.DB) {
+	t.Helper()
+	db := testDB(t)
+	signer := unsubscribe.NewSigner([]byte("secret"))
+	return NewMailer(db, fakeOrderspace(t), sender, signer, "https://example.com"), db
+}
+
+// reminderRun is one SendOrderReminders call and the counts it should report
+type reminderRun struct {
+	dryRun      bool
+	customerIDs []string
+	fail        []string // addresses whose sends fail during this run
+
+	sent, failed, skipped int
+}
+
+func TestSendOrderRemindersNeverSendsTwice(t *testing.T) {
+	tests := []struct {
+		name string
+		runs []reminderRun
+		// wantSent is how many emails each address received in total
+		wantSent map[string]int
+	}{
+		{
+			name: "re-run of the same week skips everyone",
+			runs: []reminderRun{
+				{sent: 2},
+				{skipped: 2},
+			},
+			wantSent: map[string]int{"one@example.com": 1, "two@example.com": 1},
+		},
+		{
+			name: "failed send is retried by the next run",
+			runs: []reminderRun{
+				{fail: []string{"one@example.com"}, sent: 1, failed: 1},
+				{sent: 1, skipped: 1},
+				{skipped: 2},
+			},
+			wantSent: map[string]int{"one@example.com": 1, "two@example.com": 1},
+		},
+		{
+			name: "dry run neither sends nor claims",
+			runs: []reminderRun{
+				{dryRun: true, sent: 2},
+				{sent: 2},
+				{dryRun: true, skipped: 2},
+			},
+			wantSent: map[string]int{"one@example.com": 1, "two@example.com": 1},
+		},
+		{
+			name: "listed customers share the ledger with full runs",
+			runs: []reminderRun{
+				{customerIDs: []string{"c1"}, sent: 1},
+				{sent: 1, skipped: 1},
+			},
+			wantSent: map[string]int{"one@example.com": 1, "two@example.com": 1},
+		},
+		{
+			name: "unknown customer is recorded as failed and the rest still go out",
+			runs: []reminderRun{
+				{customerIDs: []string{"gone", "c1"}, sent: 1, failed: 1},
+			},
+			wantSent: map[string]int{"one@example.com": 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			sender := &fakeSender{}
+			mailer, _ := testMailer(t, sender)
+
+			for i, run := range tt.runs {
+				sender.fail = map[string]bool{}
+				for _, to := range run.fail {
+					sender.fail[to] = true
+				}
+
+				summary, err := mailer.SendOrderReminders(ctx, RunOptions{
+					Trigger:     models.RunTriggerManual,
+					CustomerIDs: run.customerIDs,
+					DryRun:      run.dryRun,
+				})
+				if err != nil {
+					t.Fatalf("run %d: SendOrderReminders() error = %v", i, err)
+				}
+				if summary.Sent != run.sent || summary.Failed != run.failed || summary.Skipped != run.skipped {
+					t.Fatalf("run %d: sent %d, failed %d, skipped %d; want %d, %d, %d (%v)", i,
+						summary.Sent, summary.Failed, summary.Skipped, run.sent, run.failed, run.skipped, summary.Details)
+				}
+				if run.dryRun && (summary.RunID != 0 || len(summary.Emails) != run.sent) {
+					t.Fatalf("run %d: dry run recorded run %d with %d emails", i, summary.RunID, len(summary.Emails))
+				}
+			}
+
+			if len(sender.sent) != len(tt.wantSent) {
+				t.Fatalf("emails sent = %v, want %v", sender.sent, tt.wantSent)
+			}
+			for to, n := range tt.wantSent {
+				if sender.sent[to] != n {
+					t.Errorf("%s received %d emails, want %d", to, sender.sent[to], n)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryFailed(t *testing.T) {
+	ctx := context.Background()
+	sender := &fakeSender{fail: map[string]bool{"one@example.com": true}}
+	mailer, db := testMailer(t, sender)
+	reminders, err := NewReminderScheduler(mailer, database.NewReminderSchedules(db))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := reminders.Run(ctx, RunOptions{Trigger: models.RunTriggerManual, CustomerIDs: []string{"c1", "c2", "gone"}})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if first.Sent != 1 || first.Failed != 2 {
+		t.Fatalf("first run: sent %d, failed %d; want 1, 2 (%v)", first.Sent, first.Failed, first.Details)
+	}
+
+	run, err := database.NewEmailRuns(db).Get(ctx, first.RunID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := map[string]string{}
+	for _, e := range run.Emails {
+		statuses[e.CustomerID] = e.Status
+	}
+	want := map[string]string{"c1": models.SentEmailFailed, "c2": models.SentEmailSent, "gone": models.SentEmailFailed}
+	for id, status := range want {
+		if statuses[id] != status {
+			t.Errorf("run history for %s = %q, want %q", id, statuses[id], status)
+		}
+	}
+
+	// Only the failed customers are retried; the deleted one fails again
+	// without stopping c1's retry
+	sender.fail = nil
+	retry, err := reminders.RetryFailed(ctx, first.RunID)
+	if err != nil {
+		t.Fatalf("RetryFailed() error = %v", err)
+	}
+	if retry.Sent != 1 || retry.Failed != 1 || retry.Skipped != 0 {
+		t.Fatalf("retry: sent %d, failed %d, skipped %d; want 1, 1, 0 (%v)", retry.Sent, retry.Failed, retry.Skipped, retry.Details)
+	}
+	if sender.sent["one@example.com"] != 1 || sender.sent["two@example.com"] != 1 {
+		t.Errorf("emails sent = %v, want one each", sender.sent)
+	}
+
+	// A run with nothing failed can't be retried
+	clean, err := reminders.Run(ctx, RunOptions{Trigger: models.RunTriggerManual, CustomerIDs: []string{"c2"}})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if _, err := reminders.RetryFailed(ctx, clean.RunID); !errors.Is(err, ErrCannotRetry) {
+		t.Errorf("RetryFailed() on a run with no retryable failures error = %v, want ErrCannotRetry", err)
+	}
+}
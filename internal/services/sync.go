@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/DukeRupert/rr/internal/database"
+	"github.com/DukeRupert/rr/internal/models"
+	"github.com/DukeRupert/rr/internal/orderspace"
+)
+
+const (
+	syncResourceCustomers = "customers"
+	syncResourceOrders    = "orders"
+
+	// syncOverlap re-reads a little before the high-water mark so records
+	// written while the previous run was in flight aren't missed
+	syncOverlap = 5 * time.Minute
+
+	// recentDeliveryWindow is how far back orders are re-pulled by delivery
+	// date on every run, so status changes on live orders are picked up
+	// even though orders can only be listed incrementally by creation time
+	recentDeliveryWindow = 14 * 24 * time.Hour
+)
+
+// SyncResult summarises one mirror sync run
+type SyncResult struct {
+	Customers int `json:"customers"`
+	Orders    int `json:"orders"`
+}
+
+// MirrorSync incrementally copies Orderspace customers and orders into the
+// local SQLite tables
+type MirrorSync struct {
+	mirror      *database.Mirror
+	orderClient *orderspace.Client
+}
+
+func NewMirrorSync(mirror *database.Mirror, orderClient *orderspace.Client) *MirrorSync {
+	return &MirrorSync{mirror: mirror, orderClient: orderClient}
+}
+
+// Run pulls everything changed since the last successful run. Customers are
+// synced first so orders always have a parent row.
+func (s *MirrorSync) Run(ctx context.Context) (*SyncResult, error) {
+	result := &SyncResult{}
+
+	customers, err := s.syncCustomers(ctx)
+	result.Customers = customers
+	if err != nil {
+		return result, fmt.Errorf("syncing customers: %w", err)
+	}
+
+	orders, err := s.syncOrders(ctx)
+	result.Orders = orders
+	if err != nil {
+		return result, fmt.Errorf("syncing orders: %w", err)
+	}
+
+	return result, nil
+}
+
+func (s *MirrorSync) syncCustomers(ctx context.Context) (int, error) {
+	started := time.Now()
+	params := &orderspace.CustomerListParams{}
+
+	mark, err := s.mirror.SyncedThrough(ctx, syncResourceCustomers)
+	if err != nil {
+		return 0, fmt.Errorf("reading high-water mark: %w", err)
+	}
+	if !mark.IsZero() {
+		since := mark.Add(-syncOverlap)
+		params.UpdatedSince = &since
+	}
+
+	count := 0
+	err = s.orderClient.EachCustomerContext(ctx, params, func(customer models.Customer) error {
+		if err := s.mirror.UpsertCustomer(ctx, customer); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+
+	return count, s.mirror.SetSyncedThrough(ctx, syncResourceCustomers, started)
+}
+
+func (s *MirrorSync) syncOrders(ctx context.Context) (int, error) {
+	started := time.Now()
+
+	mark, err := s.mirror.SyncedThrough(ctx, syncResourceOrders)
+	if err != nil {
+		return 0, fmt.Errorf("reading high-water mark: %w", err)
+	}
+
+	passes := []*orderspace.OrderListParams{{}}
+	if !mark.IsZero() {
+		createdSince := mark.Add(-syncOverlap)
+		deliverySince := started.Add(-recentDeliveryWindow)
+		passes = []*orderspace.OrderListParams{
+			{CreatedSince: &createdSince},
+			{DeliveryDateSince: &deliverySince},
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, params := range passes {
+		err := s.orderClient.EachOrderContext(ctx, params, func(order models.Order) error {
+			if seen[order.ID] {
+				return nil
+			}
+			if err := s.upsertOrder(ctx, order); err != nil {
+				return err
+			}
+			seen[order.ID] = true
+			return nil
+		})
+		if err != nil {
+			return len(seen), err
+		}
+	}
+
+	return len(seen), s.mirror.SetSyncedThrough(ctx, syncResourceOrders, started)
+}
+
+// upsertOrder mirrors an order, first fetching its customer if the customer
+// was created after the customer pass ran
+func (s *MirrorSync) upsertOrder(ctx context.Context, order models.Order) error {
+	exists, err := s.mirror.HasCustomer(ctx, order.CustomerID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		customer, err := s.orderClient.GetCustomerContext(ctx, order.CustomerID)
+		if err != nil {
+			return fmt.Errorf("fetching customer %s for order %s: %w", order.CustomerID, order.ID, err)
+		}
+		if err := s.mirror.UpsertCustomer(ctx, *customer); err != nil {
+			return err
+		}
+	}
+
+	return s.mirror.UpsertOrder(ctx, order)
+}
+
+// runLogged runs a sync and logs the outcome, for use as a scheduled task
+func (s *MirrorSync) runLogged(ctx context.Context) error {
+	log.Printf("Starting mirror sync at: %s", time.Now().Format(time.RFC3339))
+
+	result, err := s.Run(ctx)
+	if err != nil {
+		log.Printf("ERROR mirror sync failed after %d customers, %d orders: %v", result.Customers, result.Orders, err)
+		return err
+	}
+
+	log.Printf("Completed mirror sync: %d customers, %d orders", result.Customers, result.Orders)
+	return nil
+}
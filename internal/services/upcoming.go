@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/DukeRupert/rr/internal/models"
+	"github.com/DukeRupert/rr/internal/orderspace"
+)
+
+// upcomingDeliveryWindow returns the delivery week a reminder sent at now is
+// asking customers to order for: next Monday through the following Sunday
+func upcomingDeliveryWindow(now time.Time) (start, end time.Time) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	daysUntilMonday := (int(time.Monday) - int(today.Weekday()) + 7) % 7
+	if daysUntilMonday == 0 {
+		daysUntilMonday = 7
+	}
+	start = today.AddDate(0, 0, daysUntilMonday)
+	return start, start.AddDate(0, 0, 7)
+}
+
+// orderCutoff is the day orders for the delivery week starting on start are
+// due: the Friday before
+func orderCutoff(start time.Time) time.Time {
+	return start.AddDate(0, 0, -3)
+}
+
+// coveringOrder finds an order that already covers the upcoming delivery
+// window: an active standing order, whatever its delivery date, or a
+// non-cancelled order delivering in the window. It returns nil if the
+// customer still needs to order.
+func (m *Mailer) coveringOrder(ctx context.Context, customerID string, now time.Time) (*models.Order, error) {
+	standing, err := m.firstOrder(ctx, &orderspace.OrderListParams{
+		CustomerID: customerID,
+		Status:     string(models.OrderStatusStandingOrder),
+	}, func(models.Order) bool { return true })
+	if err != nil {
+		return nil, fmt.Errorf("fetching standing orders: %w", err)
+	}
+	if standing != nil {
+		return standing, nil
+	}
+
+	start, end := upcomingDeliveryWindow(now)
+	dated, err := m.firstOrder(ctx, &orderspace.OrderListParams{
+		CustomerID:        customerID,
+		DeliveryDateSince: &start,
+	}, func(order models.Order) bool {
+		if models.OrderStatus(order.Status) == models.OrderStatusCancelled {
+			return false
+		}
+		delivery, err := time.ParseInLocation("2006-01-02", order.DeliveryDate, now.Location())
+		return err == nil && delivery.Before(end)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fetching upcoming orders: %w", err)
+	}
+	return dated, nil
+}
+
+// firstOrder returns the first order listed by params for which match is
+// true, or nil if there is none
+func (m *Mailer) firstOrder(ctx context.Context, params *orderspace.OrderListParams, match func(models.Order) bool) (*models.Order, error) {
+	var found *models.Order
+	err := m.orderClient.EachOrderContext(ctx, params, func(order models.Order) error {
+		if !match(order) {
+			return nil
+		}
+		found = &order
+		return orderspace.ErrStopIteration
+	})
+	return found, err
+}
+
+// orderedReason describes why a customer with a covering order is skipped
+func orderedReason(order *models.Order) string {
+	if models.OrderStatus(order.Status) == models.OrderStatusStandingOrder {
+		return fmt.Sprintf("has standing order #%d", order.Number)
+	}
+	return fmt.Sprintf("already ordered #%d for %s", order.Number, order.DeliveryDate)
+}
@@ -0,0 +1,207 @@
+package templates
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"text/template/parse"
+)
+
+var dataType = reflect.TypeOf(Data{})
+
+// FieldNames lists the variables templates can use, in order
+func FieldNames() []string {
+	names := make([]string, 0, dataType.NumField())
+	for i := 0; i < dataType.NumField(); i++ {
+		names = append(names, dataType.Field(i).Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkFields parses text and rejects any field reference that Data can't
+// satisfy, following dot and variables through if, range and with.
+// Rendering alone only notices fields on the branches it takes.
+func checkFields(name, text string) error {
+	t, err := texttemplate.New(name).Funcs(funcs).Parse(text)
+	if err != nil {
+		return err
+	}
+	for _, tmpl := range t.Templates() {
+		if tmpl.Tree == nil {
+			continue
+		}
+		s := scope{dot: dataType, vars: map[string]reflect.Type{"$": dataType}}
+		if err := s.list(tmpl.Tree.Root); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scope tracks the type of dot and of each variable while walking a
+// template. A nil type is a value we can't know statically, such as a
+// function result, and anything reached through it goes unchecked.
+type scope struct {
+	dot  reflect.Type
+	vars map[string]reflect.Type
+}
+
+// child starts a nested block, whose variables go out of scope at its end
+func (s scope) child(dot reflect.Type) scope {
+	vars := make(map[string]reflect.Type, len(s.vars))
+	for k, v := range s.vars {
+		vars[k] = v
+	}
+	return scope{dot: dot, vars: vars}
+}
+
+func (s scope) list(list *parse.ListNode) error {
+	if list == nil {
+		return nil
+	}
+	for _, node := range list.Nodes {
+		if err := s.node(node); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s scope) node(node parse.Node) error {
+	switch n := node.(type) {
+	case *parse.ActionNode:
+		_, err := s.pipe(n.Pipe)
+		return err
+	case *parse.TemplateNode:
+		_, err := s.pipe(n.Pipe)
+		return err
+	case *parse.IfNode:
+		return s.branch(&n.BranchNode, func(reflect.Type) reflect.Type { return s.dot })
+	case *parse.WithNode:
+		return s.branch(&n.BranchNode, func(t reflect.Type) reflect.Type { return t })
+	case *parse.RangeNode:
+		return s.branch(&n.BranchNode, elem)
+	}
+	return nil
+}
+
+// branch checks an if, range or with. dotIn gives the type of dot inside
+// the block from the type of its pipeline; else branches keep the outer dot.
+func (s scope) branch(n *parse.BranchNode, dotIn func(reflect.Type) reflect.Type) error {
+	inner := s.child(s.dot)
+	t, err := inner.pipe(n.Pipe)
+	if err != nil {
+		return err
+	}
+	inner.dot = dotIn(t)
+	if n.NodeType == parse.NodeRange && len(n.Pipe.Decl) > 0 {
+		// {{range $i, $v := ...}} or {{range $v := ...}}
+		last := n.Pipe.Decl[len(n.Pipe.Decl)-1].Ident[0]
+		inner.vars[last] = inner.dot
+		if len(n.Pipe.Decl) == 2 {
+			inner.vars[n.Pipe.Decl[0].Ident[0]] = nil
+		}
+	}
+	if err := inner.list(n.List); err != nil {
+		return err
+	}
+	return s.child(s.dot).list(n.ElseList)
+}
+
+// pipe checks a pipeline, declares its variables and returns its type
+func (s scope) pipe(p *parse.PipeNode) (reflect.Type, error) {
+	if p == nil {
+		return nil, nil
+	}
+	var t reflect.Type
+	for _, cmd := range p.Cmds {
+		for _, arg := range cmd.Args {
+			argType, err := s.arg(arg)
+			if err != nil {
+				return nil, err
+			}
+			// Only a lone field, variable or dot has a type we can follow
+			if len(p.Cmds) == 1 && len(cmd.Args) == 1 {
+				t = argType
+			}
+		}
+	}
+	for _, v := range p.Decl {
+		s.vars[v.Ident[0]] = t
+	}
+	return t, nil
+}
+
+// arg checks one command argument and returns its type, if known
+func (s scope) arg(node parse.Node) (reflect.Type, error) {
+	switch n := node.(type) {
+	case *parse.DotNode:
+		return s.dot, nil
+	case *parse.FieldNode:
+		return resolve(s.dot, n.Ident, n.String())
+	case *parse.VariableNode:
+		return resolve(s.vars[n.Ident[0]], n.Ident[1:], n.String())
+	case *parse.ChainNode:
+		t, err := s.arg(n.Node)
+		if err != nil {
+			return nil, err
+		}
+		return resolve(t, n.Field, n.String())
+	case *parse.PipeNode:
+		return s.pipe(n)
+	}
+	return nil, nil
+}
+
+// resolve follows a field path from t, failing on the first name t can't
+// have. ref is the reference as written, for the error message.
+func resolve(t reflect.Type, path []string, ref string) (reflect.Type, error) {
+	for _, name := range path {
+		if t == nil {
+			return nil, nil
+		}
+		if t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		if m, ok := t.MethodByName(name); ok {
+			if m.Type.NumOut() == 0 {
+				return nil, nil
+			}
+			t = m.Type.Out(0)
+			continue
+		}
+		switch t.Kind() {
+		case reflect.Struct:
+			f, ok := t.FieldByName(name)
+			if !ok || !f.IsExported() {
+				if t == dataType {
+					return nil, fmt.Errorf("unknown field %s (available: %s)", ref, strings.Join(FieldNames(), ", "))
+				}
+				return nil, fmt.Errorf("unknown field %s: %s has no field %s", ref, t, name)
+			}
+			t = f.Type
+		case reflect.Map:
+			t = t.Elem()
+		case reflect.Interface:
+			return nil, nil
+		default:
+			return nil, fmt.Errorf("unknown field %s: %s has no field %s", ref, t, name)
+		}
+	}
+	return t, nil
+}
+
+// elem is the type of dot inside {{range}} over a value of type t
+func elem(t reflect.Type) reflect.Type {
+	if t == nil {
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Chan:
+		return t.Elem()
+	}
+	return nil
+}
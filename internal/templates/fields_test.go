@@ -0,0 +1,97 @@
+package templates
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DukeRupert/rr/internal/models"
+)
+
+func TestCheckFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		wantErr string
+	}{
+		{"plain text", "Hello", ""},
+		{"known field", "Hi {{.CompanyName}}", ""},
+		{"every field", strings.Join([]string{
+			"{{.CompanyName}}", "{{.BuyerNames}}", "{{.OrderCutoff}}", "{{.NextDeliveryDate}}",
+			"{{.LastOrderSummary}}", "{{.LastOrderNumber}}", "{{.LastOrderDate}}", "{{.UnsubscribeURL}}",
+		}, " "), ""},
+		{"join", `{{join .BuyerNames ", "}}`, ""},
+		{"unknown field", "{{.Nope}}", ".Nope"},
+		{"unknown field lists the available ones", "{{.Nope}}", "CompanyName"},
+		{"field of a string", "{{.CompanyName.Foo}}", ".CompanyName.Foo"},
+		{"field of an int", "{{.LastOrderNumber.Foo}}", ".LastOrderNumber.Foo"},
+
+		{"if taken", "{{if .LastOrderNumber}}#{{.LastOrderNumber}}{{end}}", ""},
+		{"unknown field in if", "{{if .LastOrderNumber}}{{.Nope}}{{end}}", ".Nope"},
+		{"unknown field in if condition", "{{if .Nope}}x{{end}}", ".Nope"},
+		{"unknown field in else", "{{if .LastOrderNumber}}x{{else}}{{.Nope}}{{end}}", ".Nope"},
+		{"unknown field in else if", "{{if .LastOrderNumber}}x{{else if .CompanyName}}y{{else}}{{.CompanyName.Foo}}{{end}}", ".CompanyName.Foo"},
+
+		{"range over names", "{{range .BuyerNames}}{{.}} {{end}}", ""},
+		{"range variables", "{{range $i, $name := .BuyerNames}}{{$i}} {{$name}} {{$.CompanyName}}{{end}}", ""},
+		{"field of range element", "{{range .BuyerNames}}{{.Name}}{{end}}", ".Name"},
+		{"field of range variable", "{{range $name := .BuyerNames}}{{$name.First}}{{end}}", "$name.First"},
+		{"unknown root field in range", "{{range .BuyerNames}}{{$.Nope}}{{end}}", "$.Nope"},
+		{"unknown field in range else", "{{range .BuyerNames}}{{.}}{{else}}{{.Nope}}{{end}}", ".Nope"},
+		{"range else keeps outer dot", "{{range .BuyerNames}}{{.}}{{else}}{{.CompanyName}}{{end}}", ""},
+
+		{"with", "{{with .LastOrderSummary}}{{.}}{{end}}", ""},
+		{"field of with dot", "{{with .LastOrderSummary}}{{.Lines}}{{end}}", ".Lines"},
+		{"unknown field in with else", "{{with .LastOrderSummary}}{{.}}{{else}}{{.Nope}}{{end}}", ".Nope"},
+
+		{"declared variable", "{{$name := .CompanyName}}{{$name}}", ""},
+		{"unknown field in declaration", "{{$x := .Foo}}", ".Foo"},
+		{"field of declared variable", "{{$name := .CompanyName}}{{$name.Foo}}", "$name.Foo"},
+		{"field of declared root", "{{$d := .}}{{if false}}{{$d.Nope}}{{end}}", "$d.Nope"},
+		{"chain", "{{(.CompanyName).Foo}}", "(.CompanyName).Foo"},
+		{"function argument", "{{if false}}{{join .Nope \", \"}}{{end}}", ".Nope"},
+		{"function result is unchecked", `{{(join .BuyerNames ", ")}}`, ""},
+		{"define", `{{define "x"}}{{.Nope}}{{end}}`, ".Nope"},
+		{"syntax error", "{{.CompanyName", "unclosed action"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkFields("test", tt.text)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("checkFields(%q) error = %v", tt.text, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("checkFields(%q) error = %v, want it to mention %q", tt.text, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		content models.EmailTemplate
+		wantErr string
+	}{
+		{"valid", models.EmailTemplate{Subject: "Hi {{.CompanyName}}", HtmlBody: "<p>#{{.LastOrderNumber}}</p>", TextBody: "Hi"}, ""},
+		{"unknown field in subject", models.EmailTemplate{Subject: "Hi {{.Name}}", TextBody: "Hi"}, "subject: unknown field .Name"},
+		{"unknown field in untaken HTML branch", models.EmailTemplate{Subject: "Hi", HtmlBody: "{{if not .CompanyName}}{{.Nope}}{{end}}"}, "HTML body: unknown field .Nope"},
+		{"unknown field in text body", models.EmailTemplate{Subject: "Hi", TextBody: "{{.CompanyName.Foo}}"}, "text body: unknown field .CompanyName.Foo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(testLayout, tt.content)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
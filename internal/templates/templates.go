@@ -0,0 +1,197 @@
+// Package templates renders customer emails from stored templates. Every
+// email is a content template wrapped in the shared layout template, which
+// includes it with {{template "content" .}}. HTML bodies use html/template
+// so values are escaped; subjects and text bodies use text/template.
+//
+// Templates are executed against Data, so these variables are available:
+//
+//	{{.CompanyName}}       the customer's company name
+//	{{.BuyerNames}}        names of the customer's buyers, as a list
+//	{{.OrderCutoff}}       when orders for the upcoming delivery week are due, e.g. "Friday, October 16"
+//	{{.NextDeliveryDate}}  the first day of the upcoming delivery week, e.g. "Monday, October 19"
+//	{{.LastOrderSummary}}  the customer's most recent order, e.g. "#1042 on October 5: 2 x House Blend"
+//	{{.LastOrderNumber}}   the number of that order, e.g. 1042
+//	{{.LastOrderDate}}     the day that order was placed, e.g. "Monday, October 5"
+//	{{.UnsubscribeURL}}    the one-click link that opts the recipient out of this kind of email
+//
+// The last order variables are empty, and LastOrderNumber is 0, for
+// customers who have never ordered, so wrap them in {{if}}. The join
+// function formats lists, e.g. {{join .BuyerNames ", "}}. Referring to any
+// other variable is an error, caught by Validate before a template is used.
+package templates
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"regexp"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/DukeRupert/rr/internal/models"
+)
+
+// Names of the stored templates
+const (
+	LayoutName        = "layout"
+	OrderReminderName = "order_reminder"
+)
+
+// contentName is what the layout includes the content template as
+const contentName = "content"
+
+// Data holds the variables templates can use
+type Data struct {
+	CompanyName      string
+	BuyerNames       []string
+	OrderCutoff      string
+	NextDeliveryDate string
+	LastOrderSummary string
+	LastOrderNumber  int
+	LastOrderDate    string
+	UnsubscribeURL   string
+}
+
+// Rendered is a finished email
+type Rendered struct {
+	Subject string
+	HTML    string
+	Text    string
+}
+
+var funcs = map[string]interface{}{
+	"join": strings.Join,
+}
+
+// Render executes content inside layout against data. An empty content
+// body leaves that part of the email empty.
+func Render(layout, content models.EmailTemplate, data Data) (Rendered, error) {
+	var r Rendered
+
+	subject, err := texttemplate.New("subject").Funcs(funcs).Parse(content.Subject)
+	if err != nil {
+		return r, fmt.Errorf("parsing subject: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := subject.Execute(&buf, data); err != nil {
+		return r, fmt.Errorf("rendering subject: %w", err)
+	}
+	r.Subject = strings.TrimSpace(buf.String())
+
+	if content.HtmlBody != "" {
+		if r.HTML, err = renderHTML(layout, content, data); err != nil {
+			return r, err
+		}
+	}
+	if content.TextBody != "" {
+		if r.Text, err = renderText(layout, content, data); err != nil {
+			return r, err
+		}
+	}
+	return r, nil
+}
+
+func renderHTML(layout, content models.EmailTemplate, data Data) (string, error) {
+	var buf bytes.Buffer
+	html := htmltemplate.New(LayoutName).Funcs(funcs)
+	if _, err := html.Parse(layout.HtmlBody); err != nil {
+		return "", fmt.Errorf("parsing HTML layout: %w", err)
+	}
+	if _, err := html.New(contentName).Parse(content.HtmlBody); err != nil {
+		return "", fmt.Errorf("parsing HTML body: %w", err)
+	}
+	if err := html.ExecuteTemplate(&buf, LayoutName, data); err != nil {
+		return "", fmt.Errorf("rendering HTML body: %w", err)
+	}
+	return buf.String(), nil
+}
+
+func renderText(layout, content models.EmailTemplate, data Data) (string, error) {
+	var buf bytes.Buffer
+	text := texttemplate.New(LayoutName).Funcs(funcs)
+	if _, err := text.Parse(layout.TextBody); err != nil {
+		return "", fmt.Errorf("parsing text layout: %w", err)
+	}
+	if _, err := text.New(contentName).Parse(content.TextBody); err != nil {
+		return "", fmt.Errorf("parsing text body: %w", err)
+	}
+	if err := text.ExecuteTemplate(&buf, LayoutName, data); err != nil {
+		return "", fmt.Errorf("rendering text body: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// SampleData is example data for checking templates before they are saved
+func SampleData() Data {
+	return Data{
+		CompanyName:      "Sample Cafe",
+		BuyerNames:       []string{"Jo Sample", "Sam Sample"},
+		OrderCutoff:      "Friday, October 16",
+		NextDeliveryDate: "Monday, October 19",
+		LastOrderSummary: "#1042 on October 5: 2 x House Blend",
+		LastOrderNumber:  1042,
+		LastOrderDate:    "Monday, October 5",
+		UnsubscribeURL:   "https://example.com/unsubscribe/sample",
+	}
+}
+
+// Validate checks that content renders inside layout, catching syntax
+// errors and unknown variables before the template is used. Variables are
+// checked everywhere, including branches the sample data doesn't reach.
+func Validate(layout, content models.EmailTemplate) error {
+	parts := []struct{ name, text string }{
+		{"HTML layout", layout.HtmlBody},
+		{"text layout", layout.TextBody},
+		{"subject", content.Subject},
+		{"HTML body", content.HtmlBody},
+		{"text body", content.TextBody},
+	}
+	for _, part := range parts {
+		if err := checkFields(part.name, part.text); err != nil {
+			return fmt.Errorf("%s: %w", part.name, err)
+		}
+	}
+
+	_, err := Render(layout, content, SampleData())
+	return err
+}
+
+// ValidateLayout checks a layout renders, includes the content and keeps
+// the unsubscribe link in both bodies
+func ValidateLayout(layout models.EmailTemplate) error {
+	for _, body := range []string{layout.HtmlBody, layout.TextBody} {
+		if !strings.Contains(body, `{{template "content" .}}`) {
+			return fmt.Errorf(`layout must include {{template "content" .}} in both bodies`)
+		}
+		if !strings.Contains(body, ".UnsubscribeURL") {
+			return fmt.Errorf("layout must include {{.UnsubscribeURL}} in both bodies")
+		}
+	}
+	return Validate(layout, models.EmailTemplate{
+		Subject:  "Sample",
+		HtmlBody: "<p>Sample</p>",
+		TextBody: "Sample",
+	})
+}
+
+var (
+	documentBody = regexp.MustCompile(`(?is)<body[^>]*>(.*)</body>`)
+	documentTags = regexp.MustCompile(`(?is)<!doctype[^>]*>|<head[^>]*>.*</head>|</?html[^>]*>|</?body[^>]*>`)
+)
+
+// BodyContent returns the part of an HTML body that belongs inside the
+// layout. Full documents are reduced to what is inside their <body>, so
+// they aren't nested in the layout's own <html>; fragments are returned
+// unchanged.
+func BodyContent(html string) string {
+	if m := documentBody.FindStringSubmatch(html); m != nil {
+		return strings.TrimSpace(m[1])
+	}
+	return strings.TrimSpace(documentTags.ReplaceAllString(html, ""))
+}
+
+// FormatDate formats dates the way templates show them
+func FormatDate(t time.Time) string {
+	return t.Format("Monday, January 2")
+}
@@ -0,0 +1,88 @@
+package templates
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DukeRupert/rr/internal/models"
+)
+
+var testLayout = models.EmailTemplate{
+	HtmlBody: `<html><body>{{template "content" .}}<a href="{{.UnsubscribeURL}}">Unsubscribe</a></body></html>`,
+	TextBody: "{{template \"content\" .}}\nUnsubscribe: {{.UnsubscribeURL}}",
+}
+
+func TestBodyContent(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{"fragment", "<p>Hello</p>", "<p>Hello</p>"},
+		{"full document", "<!DOCTYPE html>\n<html><head><title>x</title></head><body class=\"a\">\n<p>Hello</p>\n</body></html>", "<p>Hello</p>"},
+		{"uppercase tags", "<HTML><BODY><p>Hello</p></BODY></HTML>", "<p>Hello</p>"},
+		{"unclosed body", "<html><body><p>Hello</p>", "<p>Hello</p>"},
+		{"merge fields kept", "<html><body><p>Hi {{.CompanyName}}</p></body></html>", "<p>Hi {{.CompanyName}}</p>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := BodyContent(tt.html); got != tt.want {
+				t.Errorf("BodyContent() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	content := models.EmailTemplate{
+		Subject:  "Hi {{.CompanyName}}",
+		HtmlBody: "<p>Hi {{.CompanyName}}</p>",
+		TextBody: "Hi {{.CompanyName}}, order by {{.OrderCutoff}}",
+	}
+	data := SampleData()
+	data.CompanyName = "Cafe <One>"
+
+	got, err := Render(testLayout, content, data)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if got.Subject != "Hi Cafe <One>" {
+		t.Errorf("Subject = %q", got.Subject)
+	}
+	if !strings.Contains(got.HTML, "<p>Hi Cafe &lt;One&gt;</p>") {
+		t.Errorf("HTML body not escaped: %q", got.HTML)
+	}
+	if strings.Count(got.HTML, "<html>") != 1 {
+		t.Errorf("HTML body should have one document: %q", got.HTML)
+	}
+	if !strings.Contains(got.Text, "order by Friday, October 16") || !strings.Contains(got.Text, data.UnsubscribeURL) {
+		t.Errorf("Text = %q", got.Text)
+	}
+}
+
+func TestValidateLayout(t *testing.T) {
+	tests := []struct {
+		name    string
+		layout  models.EmailTemplate
+		wantErr string
+	}{
+		{"valid", testLayout, ""},
+		{"missing content", models.EmailTemplate{HtmlBody: "{{.UnsubscribeURL}}", TextBody: testLayout.TextBody}, `{{template "content" .}}`},
+		{"missing unsubscribe", models.EmailTemplate{HtmlBody: `{{template "content" .}}`, TextBody: testLayout.TextBody}, "UnsubscribeURL"},
+		{"unknown field", models.EmailTemplate{HtmlBody: testLayout.HtmlBody + "{{.Nope}}", TextBody: testLayout.TextBody}, ".Nope"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateLayout(tt.layout)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ValidateLayout() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ValidateLayout() error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+// Package unsubscribe issues and verifies the signed tokens embedded in
+// unsubscribe links, so a link can only opt out the recipient it was sent to
+package unsubscribe
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/DukeRupert/rr/internal/models"
+)
+
+// ErrInvalidToken is returned for tokens that are malformed or were not
+// signed with our secret
+var ErrInvalidToken = errors.New("unsubscribe: invalid token")
+
+// Claims identify what an unsubscribe link opts out of
+type Claims struct {
+	CustomerID   string                      `json:"c"`
+	Category     models.NotificationCategory `json:"k"`
+	EmailAddress string                      `json:"e"`
+}
+
+// Signer creates and verifies tokens with an HMAC-SHA256 secret
+type Signer struct {
+	secret []byte
+}
+
+func NewSigner(secret []byte) *Signer {
+	return &Signer{secret: secret}
+}
+
+// Token encodes and signs claims as "<payload>.<signature>"
+func (s *Signer) Token(claims Claims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("encoding claims: %w", err)
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + s.sign(encoded), nil
+}
+
+// Parse verifies a token and returns its claims
+func (s *Signer) Parse(token string) (Claims, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(s.sign(encoded))) {
+		return Claims{}, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+	if claims.CustomerID == "" || !claims.Category.Validate() {
+		return Claims{}, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+func (s *Signer) sign(encoded string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}